@@ -0,0 +1,314 @@
+package bisect
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// bisect state: the reference or commit HEAD was at when bisect started,
+// and the commands that were run, kept in the git directory. Marked commits
+// are kept as references under refsPrefix: bad, good-<oid> and skip-<oid>
+const (
+	startFile  = "BISECT_START"
+	logFile    = "BISECT_LOG"
+	refsPrefix = "refs/bisect/"
+)
+
+// Term is a mark of a tested commit
+type Term string
+
+// Terms commits can be marked with. Commits after the first bad one are
+// bad, commits before it are good. Skipped commits cannot be tested
+const (
+	Good Term = "good"
+	Bad  Term = "bad"
+	Skip Term = "skip"
+)
+
+// ErrNotBisecting is returned when there is no bisect in progress
+var ErrNotBisecting = errors.New("not bisecting")
+
+// ErrBisecting is returned when starting bisect while it is in progress
+var ErrBisecting = errors.New("bisect is already in progress")
+
+// ErrNotAncestor is returned when a good commit is not in the history
+// of the bad one, so the change cannot be found between them
+var ErrNotAncestor = errors.New("good commit is not an ancestor of the bad commit")
+
+// Status is the state of the search
+type Status struct {
+	// Found is the first bad commit, once it is found
+	Found storage.OID
+	// Next is the commit checked out to be tested next
+	Next storage.OID
+	// Remaining is the number of commits that may be the first bad one
+	Remaining int
+	// Suspects are the commits that may be the first bad one, when
+	// all of the commits that could be tested were skipped
+	Suspects []storage.OID
+	// NeedGood and NeedBad tell that the search waits for commits to be
+	// marked good or bad before it can start
+	NeedGood, NeedBad bool
+}
+
+// Start begins the search, remembering what HEAD currently points to
+func Start() error {
+	if InProgress() {
+		return ErrBisecting
+	}
+	start, err := refs.Target(refs.Head)
+	if err != nil {
+		return err
+	}
+	if start == refs.Head {
+		head, err := refs.Read(refs.Head)
+		if err != nil {
+			return err
+		}
+		start = head.String()
+	}
+	err = storage.WriteFile(statePath(startFile), []byte(start))
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(statePath(logFile), []byte("gitik bisect start\n"))
+}
+
+// InProgress reports whether bisect has been started
+func InProgress() bool {
+	_, err := os.Stat(statePath(startFile))
+	return err == nil
+}
+
+// Mark marks commits with the term and checks out the next commit to test
+func Mark(term Term, oids ...storage.OID) (Status, error) {
+	if !InProgress() {
+		return Status{}, ErrNotBisecting
+	}
+	for _, oid := range oids {
+		c, err := commit.GetCommit(oid)
+		if err != nil {
+			return Status{}, err
+		}
+		name := refsPrefix + string(term)
+		if term != Bad {
+			name += "-" + oid.String()
+		}
+		err = refs.Update(name, oid, "bisect: "+string(term))
+		if err != nil {
+			return Status{}, err
+		}
+		entry := fmt.Sprintf("# %s: [%s] %s\ngitik bisect %s %s\n", term, oid, c.Subject(), term, oid)
+		err = appendLog(entry)
+		if err != nil {
+			return Status{}, err
+		}
+	}
+	return Next()
+}
+
+// Next computes the state of the search, and checks out the commit
+// that splits the remaining commits in half to be tested next
+func Next() (Status, error) {
+	bad, goods, skipped, err := marked()
+	if err != nil {
+		return Status{}, err
+	}
+	if bad == storage.ZeroOID || len(goods) == 0 {
+		return Status{NeedBad: bad == storage.ZeroOID, NeedGood: len(goods) == 0}, nil
+	}
+	for _, good := range goods {
+		ok, err := commit.IsAncestor(good, bad)
+		if err != nil {
+			return Status{}, err
+		}
+		if !ok {
+			return Status{}, fmt.Errorf("%s: %w", good, ErrNotAncestor)
+		}
+	}
+	// history is linear, so the suspects are the commits between the good
+	// ones and the bad one, newest first, with the bad one itself included
+	suspects, err := commit.LogRange([]storage.OID{bad}, goods)
+	if err != nil {
+		return Status{}, err
+	}
+	if len(suspects) == 1 {
+		return Status{Found: bad, Remaining: 1}, nil
+	}
+	middle := len(suspects) / 2
+	next, distance := -1, len(suspects)
+	for i, c := range suspects[1:] {
+		d := i + 1 - middle
+		if d < 0 {
+			d = -d
+		}
+		if !skipped[c.OID] && d < distance {
+			next, distance = i+1, d
+		}
+	}
+	status := Status{Remaining: len(suspects)}
+	if next == -1 {
+		for _, c := range suspects {
+			status.Suspects = append(status.Suspects, c.OID)
+		}
+		return status, nil
+	}
+	status.Next = suspects[next].OID
+	return status, suspects[next].Checkout(false)
+}
+
+// Reset ends the search, checking out what HEAD pointed to when it started
+func Reset() error {
+	if !InProgress() {
+		return ErrNotBisecting
+	}
+	data, err := ioutil.ReadFile(statePath(startFile))
+	if err != nil {
+		return err
+	}
+	start := strings.TrimSpace(string(data))
+	oid, err := storage.MakeOID([]byte(start))
+	if err != nil {
+		oid, err = refs.Read(start)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := commit.GetCommit(oid)
+	if err != nil {
+		return err
+	}
+	err = c.Checkout(false)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(start, "refs/") {
+		err = refs.SetSymbolic(refs.Head, start, "bisect reset: returning to "+refs.ShortName(start))
+		if err != nil {
+			return err
+		}
+	}
+	names, err := refs.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, refsPrefix) {
+			err = refs.Delete(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{startFile, logFile} {
+		err = os.Remove(statePath(name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Log returns the commands run during the search, with comments
+// describing the marked commits
+func Log() (string, error) {
+	if !InProgress() {
+		return "", ErrNotBisecting
+	}
+	data, err := ioutil.ReadFile(statePath(logFile))
+	return string(data), err
+}
+
+// Run automates the search by running the command on every commit that
+// needs to be tested, until the first bad commit is found. Exit status 0
+// marks the commit good, 125 skips it, and any other status below 128
+// marks it bad. Higher statuses, as well as failure to start the command,
+// stop the search. Every status is reported before the next test
+func Run(argv []string, report func(Status)) (Status, error) {
+	status, err := Next()
+	for err == nil && status.Next != storage.ZeroOID {
+		report(status)
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		var term Term
+		term, err = runTerm(cmd.Run())
+		if err != nil {
+			return status, fmt.Errorf("bisect run: %w", err)
+		}
+		status, err = Mark(term, status.Next)
+	}
+	if err == nil && (status.NeedBad || status.NeedGood) {
+		err = errors.New("bisect run: need both good and bad commits to start")
+	}
+	return status, err
+}
+
+func runTerm(err error) (Term, error) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return Good, err
+	}
+	switch code := exitErr.ExitCode(); {
+	case code == 125:
+		return Skip, nil
+	case code > 0 && code < 128:
+		return Bad, nil
+	default:
+		return "", err
+	}
+}
+
+// marked returns the bad commit, the good ones and the skipped ones
+func marked() (bad storage.OID, goods []storage.OID, skipped map[storage.OID]bool, err error) {
+	skipped = make(map[storage.OID]bool)
+	names, err := refs.List()
+	if err != nil {
+		return bad, nil, nil, err
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, refsPrefix) {
+			continue
+		}
+		oid, err := refs.Read(name)
+		if err != nil {
+			return bad, nil, nil, err
+		}
+		switch term := strings.TrimPrefix(name, refsPrefix); {
+		case term == string(Bad):
+			bad = oid
+		case strings.HasPrefix(term, string(Good)+"-"):
+			goods = append(goods, oid)
+		case strings.HasPrefix(term, string(Skip)+"-"):
+			skipped[oid] = true
+		}
+	}
+	return bad, goods, skipped, nil
+}
+
+func appendLog(entry string) error {
+	file, err := os.OpenFile(statePath(logFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(entry)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func statePath(name string) string {
+	return filepath.Join(constants.GitDir, name)
+}
@@ -0,0 +1,129 @@
+package blame
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Line is a line of the blamed file, together with the commit
+// that last changed it
+type Line struct {
+	Commit commit.Commit
+	// OrigLine is the number of the line in the file as of Commit,
+	// FinalLine is its number in the blamed version, both starting from 1
+	OrigLine  int
+	FinalLine int
+	Text      string
+}
+
+// File annotates every line of the file under the path, as of the start
+// commit, with the commit that introduced it. History is walked following
+// parents, and every version of the file is diffed with the previous one:
+// lines that were not carried over from the parent's version are blamed
+// on the commit. Files are tracked by path, renames are not followed
+func File(start commit.Commit, path string) ([]Line, error) {
+	blob, err := lookupBlob(start.Tree, path)
+	if err != nil {
+		return nil, err
+	}
+	if blob == storage.ZeroOID {
+		return nil, fmt.Errorf("no such file %s in %s", path, start.OID)
+	}
+	lines, err := readLines(blob)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Line, len(lines))
+	// pending maps lines of the result that are not blamed yet
+	// to their positions in the version of the file being looked at
+	pending := make(map[int]int, len(lines))
+	for i, text := range lines {
+		result[i] = Line{FinalLine: i + 1, Text: text}
+		pending[i] = i
+	}
+	c := start
+	for len(pending) > 0 {
+		var parent commit.Commit
+		parentBlob := storage.ZeroOID
+		if c.Parent != storage.ZeroOID {
+			parent, err = commit.GetCommit(c.Parent)
+			if err != nil {
+				return nil, err
+			}
+			parentBlob, err = lookupBlob(parent.Tree, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if parentBlob == blob {
+			c = parent
+			continue
+		}
+		var parentLines []string
+		if parentBlob != storage.ZeroOID {
+			parentLines, err = readLines(parentBlob)
+			if err != nil {
+				return nil, err
+			}
+		}
+		carried := carriedLines(parentLines, lines)
+		for final, current := range pending {
+			if old := carried[current]; old != -1 {
+				pending[final] = old
+				continue
+			}
+			result[final].Commit = c
+			result[final].OrigLine = current + 1
+			delete(pending, final)
+		}
+		c, blob, lines = parent, parentBlob, parentLines
+	}
+	return result, nil
+}
+
+// carriedLines maps every line of the new version to its position in the
+// old one, or to -1 if the line was added or changed
+func carriedLines(old, new []string) []int {
+	carried := make([]int, len(new))
+	i, j := 0, 0
+	copyUnchanged := func(end int) {
+		for ; j < end; i, j = i+1, j+1 {
+			carried[j] = i
+		}
+	}
+	for _, hunk := range diff.Diff(old, new) {
+		copyUnchanged(hunk.NewStart)
+		for ; j < hunk.NewEnd; j++ {
+			carried[j] = -1
+		}
+		i = hunk.OldEnd
+	}
+	copyUnchanged(len(new))
+	return carried
+}
+
+// lookupBlob returns id of the file under the path in the tree,
+// or zero OID if there is no such file
+func lookupBlob(tree storage.OID, path string) (storage.OID, error) {
+	oid, otype, err := plumbing.LookupPath(tree, path)
+	if errors.Is(err, plumbing.ErrPathNotFound) {
+		return storage.ZeroOID, nil
+	}
+	if err != nil || otype != storage.TypeBlob {
+		return storage.ZeroOID, err
+	}
+	return oid, nil
+}
+
+func readLines(blob storage.OID) ([]string, error) {
+	data, err := plumbing.ReadBlob(blob)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Lines(data), nil
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/bisect"
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(bisectCmd)
+	bisectCmd.AddCommand(bisectStartCmd, bisectGoodCmd, bisectBadCmd, bisectSkipCmd,
+		bisectResetCmd, bisectLogCmd, bisectRunCmd)
+}
+
+var bisectCmd = &cobra.Command{
+	Use:   "bisect",
+	Short: "find the commit that introduced a change by binary search",
+	Long: "check out commits between a known good and a known bad one, halving the range " +
+		"with every commit marked good or bad, until the first bad commit is found",
+}
+
+var bisectStartCmd = &cobra.Command{
+	Use:   "start [<bad> [<good>...]]",
+	Short: "start the search",
+	Long:  "start the search, optionally marking the bad commit and the good ones right away",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		err := bisect.Start()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) == 0 {
+			return
+		}
+		_, err = bisect.Mark(bisect.Bad, resolveCommits(args[:1])...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		status, err := bisect.Mark(bisect.Good, resolveCommits(args[1:])...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reportBisect(status)
+	},
+}
+
+var bisectGoodCmd = makeBisectMarkCmd(bisect.Good, "mark commits, HEAD by default, as good, made before the change")
+
+var bisectBadCmd = makeBisectMarkCmd(bisect.Bad, "mark the commit, HEAD by default, as bad, made after the change")
+
+var bisectSkipCmd = makeBisectMarkCmd(bisect.Skip, "mark commits, HEAD by default, as ones that cannot be tested")
+
+func makeBisectMarkCmd(term bisect.Term, long string) *cobra.Command {
+	args := cobra.ArbitraryArgs
+	if term == bisect.Bad {
+		args = cobra.MaximumNArgs(1)
+	}
+	return &cobra.Command{
+		Use:   string(term) + " [<commit>...]",
+		Short: "mark commits as " + string(term),
+		Long:  long,
+		Args:  args,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				args = []string{refs.Head}
+			}
+			status, err := bisect.Mark(term, resolveCommits(args)...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			reportBisect(status)
+		},
+	}
+}
+
+var bisectResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "finish the search",
+	Long:  "finish the search and check out what was checked out when it started",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		err := bisect.Reset()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var bisectLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "show what was marked so far",
+	Long:  "show commands run during the search, with comments describing the marked commits",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		text, err := bisect.Log()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(text)
+	},
+}
+
+var bisectRunCmd = &cobra.Command{
+	Use:   "run <command> [<arg>...]",
+	Short: "search automatically, testing commits with a command",
+	Long: "run the command on every commit to test. Exit status 0 marks the commit good, " +
+		"125 skips it, any other status below 128 marks it bad, higher statuses stop the search",
+	Args: cobra.MinimumNArgs(1),
+	// arguments belong to the command being run
+	DisableFlagParsing: true,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := bisect.Run(args, reportBisect)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reportBisect(status)
+	},
+}
+
+func resolveCommits(revs []string) []storage.OID {
+	var oids []storage.OID
+	for _, rev := range revs {
+		c, err := revision.ResolveCommit(rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+		oids = append(oids, c.OID)
+	}
+	return oids
+}
+
+// reportBisect tells user how the search goes
+func reportBisect(status bisect.Status) {
+	switch {
+	case status.NeedBad && status.NeedGood:
+		fmt.Println("waiting for both good and bad commits")
+	case status.NeedBad:
+		fmt.Println("waiting for a bad commit")
+	case status.NeedGood:
+		fmt.Println("waiting for good commits")
+	case status.Found != storage.ZeroOID:
+		c, err := commit.GetCommit(status.Found)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s is the first bad commit\n", c.OID)
+		fmt.Print(describeCommit(c, nil))
+	case len(status.Suspects) > 0:
+		fmt.Println("There are only skipped commits left to test.")
+		fmt.Println("The first bad commit could be any of:")
+		for _, oid := range status.Suspects {
+			fmt.Println(oid)
+		}
+	default:
+		c, err := commit.GetCommit(status.Next)
+		if err != nil {
+			log.Fatal(err)
+		}
+		steps := 0
+		for n := status.Remaining; n > 1; n /= 2 {
+			steps++
+		}
+		fmt.Printf("Bisecting: %d revisions left to test after this (roughly %d steps)\n",
+			status.Remaining/2, steps)
+		fmt.Printf("[%s] %s\n", c.OID, c.Subject())
+	}
+}
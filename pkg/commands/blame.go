@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/blame"
+	"github.com/i-hate-nicknames/gitik/pkg/identity"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	blameRangeP     string
+	blamePorcelainP bool
+)
+
+func init() {
+	rootCmd.AddCommand(blameCmd)
+	blameCmd.Flags().StringVarP(&blameRangeP, "lines", "L", "", "annotate only lines in range start,end or start,+count")
+	blameCmd.Flags().BoolVar(&blamePorcelainP, "porcelain", false, "show output in a format designed for machine consumption")
+}
+
+// blameDateFormat is the layout dates are shown with next to the lines
+const blameDateFormat = "2006-01-02 15:04:05 -0700"
+
+var blameCmd = &cobra.Command{
+	Use:   "blame <path> [<revision>]",
+	Short: "show what commit last changed each line of a file",
+	Long:  "annotate each line of the file, as of the revision (HEAD by default), with the commit that introduced it",
+	Args:  cobra.RangeArgs(1, 2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		rev := refs.Head
+		if len(args) > 1 {
+			rev = args[1]
+		}
+		c, err := revision.ResolveCommit(rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lines, err := blame.File(c, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if blameRangeP != "" {
+			start, end, err := parseLineRange(blameRangeP, len(lines))
+			if err != nil {
+				log.Fatal(err)
+			}
+			lines = lines[start-1 : end]
+		}
+		if blamePorcelainP {
+			writePorcelainBlame(args[0], lines)
+			return
+		}
+		width, numWidth := 0, len(strconv.Itoa(len(lines)))
+		for _, line := range lines {
+			if n := len(line.Commit.Author.Name); n > width {
+				width = n
+			}
+			if n := len(strconv.Itoa(line.FinalLine)); n > numWidth {
+				numWidth = n
+			}
+		}
+		for _, line := range lines {
+			when := ""
+			if !line.Commit.Author.When.IsZero() {
+				when = line.Commit.Author.When.Format(blameDateFormat)
+			}
+			fmt.Printf("%s (%-*s %s %*d) %s\n", line.Commit.OID.String()[:8], width, line.Commit.Author.Name,
+				when, numWidth, line.FinalLine, strings.TrimSuffix(line.Text, "\n"))
+		}
+	},
+}
+
+// parseLineRange parses -L range, given as start,end or start,+count,
+// either of which may be omitted, into line numbers starting from 1
+func parseLineRange(spec string, total int) (int, int, error) {
+	parts := strings.SplitN(spec, ",", 2)
+	start, end := 1, total
+	var err error
+	if parts[0] != "" {
+		start, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid line range %q", spec)
+		}
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		if strings.HasPrefix(parts[1], "+") {
+			var count int
+			count, err = strconv.Atoi(parts[1][1:])
+			end = start + count - 1
+		} else {
+			end, err = strconv.Atoi(parts[1])
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid line range %q", spec)
+		}
+	}
+	if start < 1 || start > end || end > total {
+		return 0, 0, fmt.Errorf("line range %q is outside of the file with %d lines", spec, total)
+	}
+	return start, end, nil
+}
+
+// writePorcelainBlame writes lines in the same format as git blame
+// --porcelain: every line starts with a header of commit id, original and
+// final line numbers, and the size of the group of lines coming from the
+// same commit, for the first line of the group. Information about the
+// commit follows, the first time it's seen, then the line itself after a tab
+func writePorcelainBlame(path string, lines []blame.Line) {
+	seen := make(map[storage.OID]bool)
+	for i, line := range lines {
+		header := fmt.Sprintf("%s %d %d", line.Commit.OID, line.OrigLine, line.FinalLine)
+		if i == 0 || !continuesGroup(lines[i-1], line) {
+			size := 1
+			for size < len(lines)-i && continuesGroup(lines[i+size-1], lines[i+size]) {
+				size++
+			}
+			header += fmt.Sprintf(" %d", size)
+		}
+		fmt.Println(header)
+		if !seen[line.Commit.OID] {
+			seen[line.Commit.OID] = true
+			c := line.Commit
+			writePorcelainPerson("author", c.Author)
+			writePorcelainPerson("committer", c.Committer)
+			fmt.Printf("summary %s\n", c.Subject())
+			if c.Parent == storage.ZeroOID {
+				fmt.Println("boundary")
+			}
+			fmt.Printf("filename %s\n", path)
+		}
+		fmt.Printf("\t%s\n", strings.TrimSuffix(line.Text, "\n"))
+	}
+}
+
+func continuesGroup(prev, line blame.Line) bool {
+	return prev.Commit.OID == line.Commit.OID && prev.OrigLine+1 == line.OrigLine
+}
+
+func writePorcelainPerson(role string, who identity.Signature) {
+	fmt.Printf("%s %s\n", role, who.Name)
+	fmt.Printf("%s-mail <%s>\n", role, who.Email)
+	fmt.Printf("%s-time %d\n", role, who.When.Unix())
+	fmt.Printf("%s-tz %s\n", role, who.When.Format("-0700"))
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"errors"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/sequencer"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pickContinueP bool
+	pickAbortP    bool
+)
+
+func init() {
+	rootCmd.AddCommand(cherryPickCmd)
+	cherryPickCmd.Flags().BoolVar(&pickContinueP, "continue", false, "continue after resolving conflicts")
+	cherryPickCmd.Flags().BoolVar(&pickAbortP, "abort", false, "cancel and return to the state before cherry-pick")
+	cherryPickCmd.Flags().BoolVar(&noVerifyP, "no-verify", false, "skip pre-merge hook")
+}
+
+var cherryPickCmd = &cobra.Command{
+	Use:   "cherry-pick <commit>...",
+	Short: "apply changes of existing commits on top of HEAD",
+	Long:  "for each commit, apply the changes it introduced to the current tree and commit them with the original message and author",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch {
+		case pickContinueP:
+			err = sequencer.CherryPickContinue()
+		case pickAbortP:
+			err = sequencer.CherryPickAbort()
+		case len(args) == 0:
+			log.Fatal("Expecting commits to cherry-pick")
+		default:
+			var oids []storage.OID
+			for _, arg := range args {
+				oid, err := revision.Resolve(arg)
+				if err != nil {
+					log.Fatal(err)
+				}
+				oids = append(oids, oid)
+			}
+			runPreMergeHook("cherry-pick")
+			err = sequencer.CherryPick(oids)
+		}
+		var conflict commit.ConflictError
+		if errors.As(err, &conflict) {
+			reportConflicts(conflict, "gitik cherry-pick --continue")
+		}
+		var empty commit.EmptyCommitError
+		if errors.As(err, &empty) {
+			log.Fatal("the cherry-pick is empty, as its changes are already in HEAD. " +
+				"Run \"gitik cherry-pick --continue\" to skip it, or \"gitik cherry-pick --abort\"")
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -3,88 +3,322 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/config"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/hooks"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
 	"github.com/i-hate-nicknames/gitik/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
-var messageP string
+var (
+	messageP    string
+	signP       bool
+	amendP      bool
+	allowEmptyP bool
+	noVerifyP   bool
+	forceP      bool
+	continueP   bool
+	abortP      bool
+)
 
 func init() {
 	rootCmd.AddCommand(makeCommitCmd)
 	makeCommitCmd.Flags().StringVarP(&messageP, "message", "m", "", "commit message")
-	rootCmd.AddCommand(logCmd)
+	makeCommitCmd.Flags().BoolVarP(&signP, "sign", "S", false, "sign the commit with the key set by "+signingKeyOption+" option")
+	makeCommitCmd.Flags().BoolVar(&amendP, "amend", false, "replace the last commit with a new one, reusing its message unless -m is given")
+	makeCommitCmd.Flags().BoolVar(&allowEmptyP, "allow-empty", false, "allow commit that changes nothing")
+	makeCommitCmd.Flags().BoolVarP(&noVerifyP, "no-verify", "n", false, "skip pre-commit and commit-msg hooks")
 	rootCmd.AddCommand(checkoutCmd)
+	checkoutCmd.Flags().BoolVarP(&forceP, "force", "f", false, "discard local changes to the files and overwrite untracked files")
+	checkoutCmd.Flags().BoolVar(&continueP, "continue", false, "finish the interrupted checkout")
+	checkoutCmd.Flags().BoolVar(&abortP, "abort", false, "undo the interrupted checkout")
 }
 
 var makeCommitCmd = &cobra.Command{
 	Use:   "commit",
 	Short: "commit changes to the repository",
-	Long:  "write current tree with given message and store it separately",
+	Long: "write current tree with given message and store it separately. " +
+		"Without -m the message is edited in $GITIK_EDITOR, $VISUAL or $EDITOR, " +
+		"starting from the file set by " + commitTemplateOption + " option, if any. " +
+		"Runs pre-commit, commit-msg and post-commit hooks",
 
 	Run: func(cmd *cobra.Command, args []string) {
-		treeOID, err := commit.SaveCurrentTree(messageP)
+		if !noVerifyP {
+			err := hooks.Run(hooks.PreCommit)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		message := messageP
+		if !cmd.Flags().Changed("message") {
+			var err error
+			message, err = editCommitMessage(amendP)
+			if err != nil && !errors.Is(err, commit.ErrEmptyMessage) {
+				reportCommitError(err)
+			}
+		}
+		if strings.TrimSpace(message) == "" {
+			log.Fatal("Aborting commit due to empty commit message")
+		}
+		if !noVerifyP {
+			var err error
+			message, err = runCommitMsgHook(message)
+			if errors.Is(err, commit.ErrEmptyMessage) {
+				log.Fatal("Aborting commit, commit-msg hook left the message empty")
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		opts := commit.Options{Amend: amendP, AllowEmpty: allowEmptyP}
+		if signP {
+			key, err := signingKey()
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.SigningKey = key
+		}
+		treeOID, err := commit.SaveCurrentTree(message, opts)
 		if err != nil {
-			log.Fatal(err)
+			reportCommitError(err)
 		}
 		fmt.Println(treeOID)
+		// commit is already made, failure of the hook changes nothing
+		_ = hooks.Run(hooks.PostCommit)
 	},
 }
 
-var logCmd = &cobra.Command{
-	Use:   "log",
-	Short: "get commit history",
-	Long:  "get commit history ordered from newest to oldest",
+// runCommitMsgHook lets commit-msg hook check and edit the message.
+// Message is checked again, since the hook may have emptied it
+func runCommitMsgHook(message string) (string, error) {
+	path := filepath.Join(constants.GitDir, constants.CommitEditMsgName)
+	err := storage.WriteFile(path, []byte(message+"\n"))
+	if err != nil {
+		return "", err
+	}
+	err = hooks.Run(hooks.CommitMsg, path)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message = strings.TrimSpace(string(data))
+	if message == "" {
+		return "", commit.ErrEmptyMessage
+	}
+	return message, nil
+}
 
-	Run: func(cmd *cobra.Command, args []string) {
-		var commitLog []commit.Commit
-		var err error
-		if len(args) > 0 {
-			commitOID, err := storage.MakeOID([]byte(args[0]))
-			if err != nil {
-				log.Fatal(err)
-			}
-			commitLog, err = commit.LogFrom(commitOID)
-		} else {
-			commitLog, err = commit.Log()
-		}
+// commitTemplateOption is path to the file with initial commit message
+const commitTemplateOption = "commit.template"
 
-		if errors.Is(err, commit.ErrNoHead) {
-			fmt.Println("No commits found")
-			return
+// editCommitMessage lets user write commit message in the editor. It starts
+// with the message of the amended commit, the message of the operation
+// stopped by conflicts, or the template
+func editCommitMessage(amend bool) (string, error) {
+	head, err := commit.GetHead()
+	if err != nil && !errors.Is(err, commit.ErrNoHead) {
+		return "", err
+	}
+	base := head.Tree
+	message := ""
+	if amend {
+		if err != nil {
+			return "", fmt.Errorf("nothing to amend: %w", err)
 		}
+		message = head.Message
+		base, err = parentTree(head)
+	} else {
+		message, err = commit.PendingMessage()
+	}
+	if err != nil {
+		return "", err
+	}
+	if message == "" {
+		path, err := config.Path(commitTemplateOption, "")
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
-		for _, commit := range commitLog {
-			fmt.Printf("commit %s\n\n", commit.OID)
-			fmt.Printf("    %s\n", commit.Message)
+		if path != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			message = string(data)
 		}
-	},
+	}
+	summary, changed, err := changesSummary(base)
+	if err != nil {
+		return "", err
+	}
+	if !changed && !allowEmptyP {
+		// don't let user write a message for a commit that will be refused
+		return "", commit.EmptyCommitError{Tree: base}
+	}
+	return commit.EditMessage(strings.TrimRight(message, "\n"), summary)
+}
+
+var changeLabels = map[string]string{
+	"A": "new file",
+	"D": "deleted",
+	"M": "modified",
+}
+
+// reportCommitError exits with the error, suggesting a way out if possible
+func reportCommitError(err error) {
+	var empty commit.EmptyCommitError
+	if errors.As(err, &empty) {
+		log.Fatalf("%s (use --allow-empty to commit anyway)", err)
+	}
+	log.Fatal(err)
+}
+
+// changesSummary describes current branch and changes of the working tree
+// since the base tree, as comments of the commit message.
+// Return whether there are any changes
+func changesSummary(base storage.OID) (string, bool, error) {
+	var buf strings.Builder
+	target, err := refs.Target(refs.Head)
+	if err != nil {
+		return "", false, err
+	}
+	head, err := commit.GetHead()
+	if err != nil && !errors.Is(err, commit.ErrNoHead) {
+		return "", false, err
+	}
+	switch {
+	case target != refs.Head:
+		fmt.Fprintf(&buf, "# On branch %s\n", refs.ShortName(target))
+	case err == nil:
+		fmt.Fprintf(&buf, "# HEAD detached at %s\n", head.OID.String()[:7])
+	}
+	if err != nil {
+		buf.WriteString("#\n# Initial commit\n")
+	}
+	baseFiles, err := plumbing.ReadTreeFiles(base)
+	if err != nil {
+		return "", false, err
+	}
+	files, err := commit.CurrentFiles()
+	if err != nil {
+		return "", false, err
+	}
+	changes := diff.Files(baseFiles, files)
+	if len(changes) == 0 {
+		buf.WriteString("#\n# No changes\n")
+	}
+	if len(changes) > 0 {
+		buf.WriteString("#\n# Changes to be committed:\n")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(&buf, "#\t%-12s%s\n", changeLabels[change.Status()]+":", change.Path)
+	}
+	// without a branch line the summary starts with a separator
+	return strings.TrimPrefix(buf.String(), "#\n"), len(changes) > 0, nil
+}
+
+// parentTree returns tree of the commit's parent, zero for a root commit
+func parentTree(c commit.Commit) (storage.OID, error) {
+	if c.Parent == storage.ZeroOID {
+		return storage.ZeroOID, nil
+	}
+	parent, err := commit.GetCommit(c.Parent)
+	return parent.Tree, err
 }
 
 var checkoutCmd = &cobra.Command{
 	Use:   "checkout",
 	Short: "check out given commit, resetting working tree to it",
-	Long:  "set working tree to the tree of the commit and update HEAD",
+	Long: "set working tree to the tree of the commit and update HEAD. Local changes and untracked files " +
+		"are kept, checkout is refused if either would be overwritten. Only the changed files are written, " +
+		"interrupted checkout can be finished with --continue or undone with --abort. Runs post-checkout hook",
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if continueP || abortP {
+			resumeCheckout()
+			return
+		}
 		if len(args) != 1 {
 			log.Fatalf("Expecting commit hash")
 		}
-		commitOID, err := storage.MakeOID([]byte(args[0]))
+		c, err := revision.ResolveCommit(args[0])
 		if err != nil {
 			log.Fatalf(err.Error())
 		}
-		c, err := commit.GetCommit(commitOID)
+		previous, err := refs.Read(refs.Head)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			log.Fatal(err)
+		}
+		err = c.Checkout(forceP)
+		var dirty commit.DirtyTreeError
+		if errors.As(err, &dirty) {
+			fmt.Println("Your local changes to the following files would be overwritten by checkout:")
+			for _, path := range dirty.Paths {
+				fmt.Printf("\t%s\n", path)
+			}
+			log.Fatal("Please commit or stash your changes, or use --force to discard them")
+		}
+		var untracked commit.UntrackedFilesError
+		if errors.As(err, &untracked) {
+			fmt.Println("The following untracked files would be overwritten by checkout:")
+			for _, path := range untracked.Paths {
+				fmt.Printf("\t%s\n", path)
+			}
+			log.Fatal("Please move or remove them, or use --force to overwrite them")
+		}
+		if errors.Is(err, commit.ErrCheckoutInterrupted) {
+			log.Fatalf("%s (use --continue or --abort)", err)
+		}
 		if err != nil {
 			log.Fatalf(err.Error())
 		}
-		err = c.Checkout(true)
+		err = hooks.Run(hooks.PostCheckout, previous.String(), c.OID.String(), "1")
 		if err != nil {
-			log.Fatalf(err.Error())
+			// checkout is already done, only the exit status is affected
+			log.Fatal(err)
 		}
 	},
 }
+
+// resumeCheckout finishes or undoes the interrupted checkout. Post-checkout
+// hook is run once checkout is finished
+func resumeCheckout() {
+	if continueP && abortP {
+		log.Fatal("--continue and --abort cannot be used together")
+	}
+	if abortP {
+		err := commit.AbortCheckout()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	previous, err := refs.Read(refs.Head)
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		log.Fatal(err)
+	}
+	err = commit.ContinueCheckout()
+	if err != nil {
+		log.Fatal(err)
+	}
+	current, err := refs.Read(refs.Head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = hooks.Run(hooks.PostCheckout, previous.String(), current.String(), "1")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
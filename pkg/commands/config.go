@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config <section.key> [<value>]",
+	Short: "get or set repository options",
+	Long:  "print value of the option, or set it when the value is given. Options are stored in .gitik/config",
+	Args:  cobra.RangeArgs(1, 2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 2 {
+			err := config.Set(args[0], args[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		value, err := config.Get(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(value)
+	},
+}
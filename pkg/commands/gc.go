@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "optimize the repository",
+	Long: "do housekeeping that speeds up other commands. Currently writes the commit-graph, " +
+		"that lets log, merge base and ancestry queries traverse history without reading commit objects",
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		n, err := commit.WriteGraph()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Wrote commit-graph with %d commits\n", n)
+	},
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmCachedP bool
+	rmForceP  bool
+)
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(rmCmd)
+	rmCmd.Flags().BoolVar(&rmCachedP, "cached", false, "only remove from the index, keeping the files")
+	rmCmd.Flags().BoolVarP(&rmForceP, "force", "f", false, "remove files even if they have changes that are not in the index")
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <path>...",
+	Short: "add files to the index",
+	Long: "stage current contents of the files for the next commit. Directories are added with all of their files, " +
+		"files that were deleted are removed from the index. The first add starts the index with the files of HEAD, " +
+		"from then on commit is made from the index instead of the whole working tree",
+	Args: cobra.MinimumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		idx := readIndex()
+		for _, path := range args {
+			_, _, err := idx.Add(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		err := idx.Write()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm [--cached] <path>...",
+	Short: "remove files from the index and the working tree",
+	Long: "stage removal of the files for the next commit and delete them, or keep them with --cached. " +
+		"Files whose contents differ from the index are not removed without --force",
+	Args: cobra.MinimumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		idx := readIndex()
+		var removed []index.Entry
+		for _, path := range args {
+			entries, err := idx.Remove(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			removed = append(removed, entries...)
+		}
+		if !rmCachedP && !rmForceP {
+			for _, entry := range removed {
+				modified, err := differsFrom(entry)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if modified {
+					log.Fatalf("%s has local modifications (use --cached to keep the file, or --force to remove it)", entry.Path)
+				}
+			}
+		}
+		err := idx.Write()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range removed {
+			fmt.Printf("rm '%s'\n", entry.Path)
+			if rmCachedP {
+				continue
+			}
+			err := os.Remove(entry.Path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+// readIndex reads the index, or starts one with the files of HEAD
+func readIndex() *index.Index {
+	head, err := commit.GetHead()
+	if err != nil && !errors.Is(err, commit.ErrNoHead) {
+		log.Fatal(err)
+	}
+	idx, err := index.ReadOrCreate(head.Tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return idx
+}
+
+// differsFrom reports whether the file has contents other than the ones
+// in the index entry. Missing file does not differ
+func differsFrom(entry index.Entry) (bool, error) {
+	info, err := os.Stat(entry.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if entry.Unchanged(info) {
+		return false, nil
+	}
+	oid, err := plumbing.HashFile(entry.Path)
+	if err != nil {
+		return false, err
+	}
+	return oid != entry.OID, nil
+}
@@ -0,0 +1,334 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/graph"
+	"github.com/i-hate-nicknames/gitik/pkg/notes"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/signing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logMaxCountP int
+	logOnelineP  bool
+	logFormatP   string
+	logReverseP  bool
+	logSinceP    string
+	logUntilP    string
+	logGrepP     string
+	logAuthorP   string
+	logGraphP    bool
+	logAllP      bool
+	logSignP     bool
+	logNoNotesP  bool
+)
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().IntVarP(&logMaxCountP, "max-count", "n", -1, "show at most given number of commits")
+	logCmd.Flags().BoolVar(&logOnelineP, "oneline", false, "show every commit as an abbreviated id and subject on a single line")
+	logCmd.Flags().StringVar(&logFormatP, "format", "", "show commits using a template with placeholders like %h, %an, %ad and %s")
+	logCmd.Flags().BoolVar(&logReverseP, "reverse", false, "show oldest commits first")
+	logCmd.Flags().StringVar(&logSinceP, "since", "", "show commits made after given date")
+	logCmd.Flags().StringVar(&logUntilP, "until", "", "show commits made before given date")
+	logCmd.Flags().StringVar(&logGrepP, "grep", "", "show commits with message matching given regular expression")
+	logCmd.Flags().StringVar(&logAuthorP, "author", "", "show commits with author matching given regular expression")
+	logCmd.Flags().BoolVar(&logGraphP, "graph", false, "draw history graph next to commits")
+	logCmd.Flags().BoolVar(&logAllP, "all", false, "show history of all references, as if they were given as arguments")
+	logCmd.Flags().BoolVar(&logSignP, "show-signature", false, "check signatures of commits and show the result")
+	logCmd.Flags().BoolVar(&logNoNotesP, "no-notes", false, "do not show notes attached to commits")
+}
+
+var logCmd = &cobra.Command{
+	Use:   "log [<revision range>...]",
+	Short: "get commit history",
+	Long: "get commit history ordered from newest to oldest, starting from HEAD by default. " +
+		"Revision range A..B stands for commits reachable from B but not from A, " +
+		"A...B for commits reachable from either A or B, but not from both, " +
+		"^A excludes commits reachable from A",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if logGraphP && logReverseP {
+			log.Fatal("--reverse and --graph cannot be used together")
+		}
+		if logAllP {
+			all, err := refs.List()
+			if err != nil {
+				log.Fatal(err)
+			}
+			args = append(append(args, refs.Head), all...)
+		}
+		include, exclude, err := parseLogRange(args)
+		if errors.Is(err, commit.ErrNoHead) {
+			fmt.Println("No commits found")
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		commitLog, err := commit.LogRange(include, exclude)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filter, err := newLogFilter()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var shown []commit.Commit
+		for _, c := range commitLog {
+			if logMaxCountP >= 0 && len(shown) == logMaxCountP {
+				break
+			}
+			if filter.matches(c) {
+				shown = append(shown, c)
+			}
+		}
+		if logReverseP {
+			for i, j := 0, len(shown)-1; i < j; i, j = i+1, j-1 {
+				shown[i], shown[j] = shown[j], shown[i]
+			}
+		}
+		decorations, err := refDecorations()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var trusted []signing.TrustedKey
+		if logSignP {
+			trusted, err = trustedKeys()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		describe := func(c commit.Commit) string {
+			text := describeCommit(c, decorations[c.OID])
+			if !logSignP {
+				return text
+			}
+			status, _ := describeSignature(c, trusted)
+			lines := strings.SplitN(text, "\n", 2)
+			if len(lines) == 1 {
+				return text + "\n" + status
+			}
+			// signature status goes right after the commit line
+			return lines[0] + "\n" + status + "\n" + lines[1]
+		}
+		if logGraphP {
+			err = graph.Render(os.Stdout, shown, describe)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		for _, c := range shown {
+			fmt.Println(describe(c))
+		}
+	},
+}
+
+// parseLogRange returns commits to include in the log together with their
+// history, and commits whose history should be excluded from it
+func parseLogRange(args []string) (include, exclude []storage.OID, err error) {
+	resolve := func(rev string) (storage.OID, error) {
+		if rev == "" {
+			rev = refs.Head
+		}
+		c, err := revision.ResolveCommit(rev)
+		return c.OID, err
+	}
+	for _, arg := range args {
+		if parts := strings.SplitN(arg, "...", 2); len(parts) == 2 {
+			a, err := resolve(parts[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := resolve(parts[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			base, err := commit.MergeBase(a, b)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, a, b)
+			if base != storage.ZeroOID {
+				exclude = append(exclude, base)
+			}
+			continue
+		}
+		if parts := strings.SplitN(arg, "..", 2); len(parts) == 2 {
+			a, err := resolve(parts[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := resolve(parts[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			exclude = append(exclude, a)
+			include = append(include, b)
+			continue
+		}
+		if strings.HasPrefix(arg, "^") {
+			oid, err := resolve(arg[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			exclude = append(exclude, oid)
+			continue
+		}
+		oid, err := resolve(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		include = append(include, oid)
+	}
+	if len(include) == 0 {
+		head, err := commit.GetHead()
+		if err != nil {
+			return nil, nil, err
+		}
+		include = append(include, head.OID)
+	}
+	return include, exclude, nil
+}
+
+// logFilter selects commits to show by their date, message and author
+type logFilter struct {
+	since, until time.Time
+	grep, author *regexp.Regexp
+}
+
+func newLogFilter() (logFilter, error) {
+	var filter logFilter
+	var err error
+	now := time.Now()
+	if logSinceP != "" {
+		filter.since, err = revision.ParseDate(logSinceP, now)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if logUntilP != "" {
+		filter.until, err = revision.ParseDate(logUntilP, now)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if logGrepP != "" {
+		filter.grep, err = regexp.Compile(logGrepP)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if logAuthorP != "" {
+		filter.author, err = regexp.Compile(logAuthorP)
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func (f logFilter) matches(c commit.Commit) bool {
+	when := c.Committer.When
+	if !f.since.IsZero() && when.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && when.After(f.until) {
+		return false
+	}
+	if f.grep != nil && !f.grep.MatchString(c.Message) {
+		return false
+	}
+	if f.author != nil && !f.author.MatchString(c.Author.Identity.String()) {
+		return false
+	}
+	return true
+}
+
+// refDecorations returns names of references pointing to every commit,
+// in the form they are shown next to commit ids
+func refDecorations() (map[storage.OID][]string, error) {
+	decorations := make(map[storage.OID][]string)
+	headTarget, err := refs.Target(refs.Head)
+	if err != nil {
+		return nil, err
+	}
+	if headTarget == refs.Head {
+		if head, err := refs.Read(refs.Head); err == nil {
+			decorations[head] = append(decorations[head], refs.Head)
+		}
+	}
+	names, err := refs.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		oid, err := refs.Read(name)
+		if err != nil {
+			continue
+		}
+		label := name
+		switch {
+		case strings.HasPrefix(name, "refs/heads/"):
+			label = refs.ShortName(name)
+		case strings.HasPrefix(name, "refs/tags/"):
+			label = "tag: " + refs.ShortName(name)
+		}
+		if name == headTarget {
+			label = refs.Head + " -> " + label
+		}
+		decorations[oid] = append(decorations[oid], label)
+	}
+	return decorations, nil
+}
+
+// describeCommit returns text the commit is shown with, according to
+// the output format options
+func describeCommit(c commit.Commit, decorations []string) string {
+	decoration := ""
+	if len(decorations) > 0 {
+		decoration = " (" + strings.Join(decorations, ", ") + ")"
+	}
+	switch {
+	case logFormatP != "":
+		return c.Format(logFormatP)
+	case logOnelineP:
+		return c.Format("%h") + decoration + " " + c.Subject()
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "commit %s%s\n", c.OID, decoration)
+	if c.Author.Name != "" {
+		fmt.Fprintf(&buf, "Author: %s\n", c.Author.Identity)
+		fmt.Fprintf(&buf, "Date:   %s\n", commit.FormatDate(c.Author.When))
+	}
+	buf.WriteString("\n")
+	for _, line := range strings.Split(c.Message, "\n") {
+		fmt.Fprintf(&buf, "    %s\n", line)
+	}
+	if logNoNotesP {
+		return buf.String()
+	}
+	note, err := notes.Get(c.OID)
+	if err != nil && !errors.Is(err, notes.ErrNoNote) {
+		log.Fatal(err)
+	}
+	if err == nil {
+		buf.WriteString("\nNotes:\n")
+		for _, line := range strings.Split(strings.TrimRight(note, "\n"), "\n") {
+			fmt.Fprintf(&buf, "    %s\n", line)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/editor"
+	"github.com/i-hate-nicknames/gitik/pkg/notes"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notesMessageP string
+	notesForceP   bool
+)
+
+func init() {
+	rootCmd.AddCommand(notesCmd)
+	notesCmd.AddCommand(notesAddCmd, notesShowCmd, notesEditCmd, notesRemoveCmd, notesListCmd)
+	notesAddCmd.Flags().StringVarP(&notesMessageP, "message", "m", "", "text of the note")
+	notesAddCmd.Flags().BoolVarP(&notesForceP, "force", "f", false, "replace existing note")
+}
+
+// notesEditMsgName is filename of the note being edited by the user
+const notesEditMsgName = "NOTES_EDITMSG"
+
+var notesCmd = &cobra.Command{
+	Use:   "notes",
+	Short: "annotate commits without changing them",
+	Long:  "attach notes to commits, kept apart from the commits under " + notes.Ref + ", so that commit ids stay the same",
+}
+
+var notesAddCmd = &cobra.Command{
+	Use:   "add [<commit>]",
+	Short: "add a note to the commit",
+	Long:  "attach a note to the commit, HEAD by default. Without -m the note is written in the editor",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		c := resolveNoteCommit(args)
+		text := notesMessageP
+		if !cmd.Flags().Changed("message") {
+			var err error
+			text, err = editNote(c, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if text == "" {
+			log.Fatal("Aborting, the note is empty")
+		}
+		err := notes.Add(c.OID, text+"\n", notesForceP)
+		if errors.Is(err, notes.ErrNoteExists) {
+			log.Fatalf("%s (use -f to overwrite it)", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var notesShowCmd = &cobra.Command{
+	Use:   "show [<commit>]",
+	Short: "show the note of the commit",
+	Long:  "show the note attached to the commit, HEAD by default",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		c := resolveNoteCommit(args)
+		text, err := notes.Get(c.OID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(text)
+	},
+}
+
+var notesEditCmd = &cobra.Command{
+	Use:   "edit [<commit>]",
+	Short: "edit the note of the commit",
+	Long:  "edit the note attached to the commit, HEAD by default, in the editor. Emptying the note removes it",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		c := resolveNoteCommit(args)
+		text, err := notes.Get(c.OID)
+		if err != nil && !errors.Is(err, notes.ErrNoNote) {
+			log.Fatal(err)
+		}
+		hadNote := err == nil
+		text, err = editNote(c, text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch {
+		case text != "":
+			err = notes.Add(c.OID, text+"\n", true)
+		case hadNote:
+			err = notes.Remove(c.OID)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var notesRemoveCmd = &cobra.Command{
+	Use:   "remove [<commit>]",
+	Short: "remove the note of the commit",
+	Long:  "remove the note attached to the commit, HEAD by default",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		c := resolveNoteCommit(args)
+		err := notes.Remove(c.OID)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var notesListCmd = &cobra.Command{
+	Use:   "list [<commit>]",
+	Short: "list notes",
+	Long:  "list all notes as ids of the note and the annotated object, or the id of the note of the given commit",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		list, err := notes.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		object := storage.ZeroOID
+		if len(args) > 0 {
+			object = resolveNoteCommit(args).OID
+		}
+		for _, note := range list {
+			switch {
+			case object == storage.ZeroOID:
+				fmt.Printf("%s %s\n", note.Blob, note.Object)
+			case object == note.Object:
+				fmt.Println(note.Blob)
+				return
+			}
+		}
+		if object != storage.ZeroOID {
+			log.Fatalf("%s: %s", object, notes.ErrNoNote)
+		}
+	},
+}
+
+func resolveNoteCommit(args []string) commit.Commit {
+	rev := refs.Head
+	if len(args) > 0 {
+		rev = args[0]
+	}
+	c, err := revision.ResolveCommit(rev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
+// editNote lets user write the note of the commit in the editor,
+// starting with the given text
+func editNote(c commit.Commit, text string) (string, error) {
+	path := filepath.Join(constants.GitDir, notesEditMsgName)
+	help := fmt.Sprintf("\n# Write or edit the note for the commit\n#   %s %s\n"+
+		"# Lines starting with '#' will be ignored.\n", c.OID.String()[:7], c.Subject())
+	err := storage.WriteFile(path, []byte(text+help))
+	if err != nil {
+		return "", err
+	}
+	err = editor.Edit(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return editor.StripComments(string(data)), nil
+}
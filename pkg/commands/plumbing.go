@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
 	"github.com/i-hate-nicknames/gitik/pkg/storage"
 	"github.com/spf13/cobra"
 )
@@ -23,7 +24,7 @@ var catFileCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
-		oid, err := storage.MakeOID([]byte(args[0]))
+		oid, err := revision.Resolve(args[0])
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -42,7 +43,7 @@ var readTreeCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
-		oid, err := storage.MakeOID([]byte(args[0]))
+		oid, err := revision.ResolveTree(args[0])
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/sequencer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rebaseInteractiveP bool
+	rebaseContinueP    bool
+	rebaseSkipP        bool
+	rebaseAbortP       bool
+)
+
+func init() {
+	rootCmd.AddCommand(rebaseCmd)
+	rebaseCmd.Flags().BoolVarP(&rebaseInteractiveP, "interactive", "i", false, "edit the list of commits to rebase")
+	rebaseCmd.Flags().BoolVar(&rebaseContinueP, "continue", false, "continue after resolving conflicts or editing a commit")
+	rebaseCmd.Flags().BoolVar(&rebaseSkipP, "skip", false, "skip the commit rebase stopped at")
+	rebaseCmd.Flags().BoolVar(&rebaseAbortP, "abort", false, "cancel and return to the state before rebase")
+	rebaseCmd.Flags().BoolVar(&noVerifyP, "no-verify", false, "skip pre-merge hook")
+}
+
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase [-i] <upstream>",
+	Short: "replay commits on top of another base",
+	Long:  "reapply commits that are not reachable from upstream on top of it, and move HEAD to the result",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch {
+		case rebaseContinueP:
+			err = sequencer.RebaseContinue()
+		case rebaseSkipP:
+			err = sequencer.RebaseSkip()
+		case rebaseAbortP:
+			err = sequencer.RebaseAbort()
+		case len(args) == 0:
+			log.Fatal("Expecting upstream commit")
+		default:
+			upstream, rerr := revision.Resolve(args[0])
+			if rerr != nil {
+				log.Fatal(rerr)
+			}
+			runPreMergeHook("rebase")
+			err = sequencer.Rebase(upstream, rebaseInteractiveP)
+		}
+		var conflict commit.ConflictError
+		if errors.As(err, &conflict) {
+			reportConflicts(conflict, "gitik rebase --continue")
+		}
+		if errors.Is(err, sequencer.ErrStopped) || errors.Is(err, sequencer.ErrUpToDate) {
+			fmt.Println(err)
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
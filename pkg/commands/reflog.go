@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(reflogCmd)
+}
+
+var reflogCmd = &cobra.Command{
+	Use:   "reflog [ref]",
+	Short: "show history of reference movements",
+	Long:  "list every position the reference (HEAD by default) pointed to, newest first",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		name := refs.Head
+		if len(args) > 0 {
+			var err error
+			name, err = revision.RefName(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		entries, err := refs.ReadLog(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, entry := range entries {
+			fmt.Printf("%s %s@{%d}: %s\n", entry.New.String()[:7], name, i, entry.Reason)
+		}
+	},
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resetSoftP  bool
+	resetMixedP bool
+	resetHardP  bool
+)
+
+func init() {
+	rootCmd.AddCommand(resetCmd)
+	resetCmd.Flags().BoolVar(&resetSoftP, "soft", false, "only move HEAD")
+	resetCmd.Flags().BoolVar(&resetMixedP, "mixed", false, "move HEAD and reset the index (default)")
+	resetCmd.Flags().BoolVar(&resetHardP, "hard", false, "move HEAD and reset the working tree")
+}
+
+var resetCmd = &cobra.Command{
+	Use:   "reset [--soft | --mixed | --hard] [commit]",
+	Short: "move HEAD to the given commit",
+	Long:  "set current HEAD to the given commit (HEAD by default), optionally resetting the working tree",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		mode := commit.ResetMixed
+		modes := 0
+		if resetSoftP {
+			mode = commit.ResetSoft
+			modes++
+		}
+		if resetMixedP {
+			mode = commit.ResetMixed
+			modes++
+		}
+		if resetHardP {
+			mode = commit.ResetHard
+			modes++
+		}
+		if modes > 1 {
+			log.Fatal("only one of --soft, --mixed and --hard can be given")
+		}
+		target := refs.Head
+		if len(args) > 0 {
+			target = args[0]
+		}
+		c, err := revision.ResolveCommit(target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = c.Reset(mode)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
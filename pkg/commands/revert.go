@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/hooks"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(revertCmd)
+	revertCmd.Flags().BoolVar(&noVerifyP, "no-verify", false, "skip pre-merge hook")
+}
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <commit>",
+	Short: "create a commit undoing changes of the given commit",
+	Long:  "apply inverse of the changes introduced by the commit to the current tree and commit the result",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := revision.ResolveCommit(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		runPreMergeHook("revert")
+		revertOID, err := commit.Revert(c)
+		var conflict commit.ConflictError
+		if errors.As(err, &conflict) {
+			reportConflicts(conflict, "gitik commit")
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(revertOID)
+	},
+}
+
+// runPreMergeHook runs pre-merge hook for the command, unless --no-verify
+// is given, and exits if the hook fails
+func runPreMergeHook(command string) {
+	if noVerifyP {
+		return
+	}
+	err := hooks.Run(hooks.PreMerge, command)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reportConflicts tells user which files need to be resolved by hand,
+// and how to proceed after that, and exits
+func reportConflicts(conflict commit.ConflictError, proceed string) {
+	for _, path := range conflict.Paths {
+		fmt.Printf("CONFLICT: %s\n", path)
+	}
+	log.Fatalf("fix conflicts and run \"%s\" to record the result", proceed)
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shortlogNumberedP bool
+	shortlogSummaryP  bool
+	shortlogEmailP    bool
+	statsPeriodP      string
+)
+
+func init() {
+	rootCmd.AddCommand(shortlogCmd, statsCmd)
+	shortlogCmd.Flags().BoolVarP(&shortlogNumberedP, "numbered", "n", false, "sort authors by the number of commits instead of by name")
+	shortlogCmd.Flags().BoolVarP(&shortlogSummaryP, "summary", "s", false, "show only the number of commits of every author")
+	shortlogCmd.Flags().BoolVarP(&shortlogEmailP, "email", "e", false, "show email of every author")
+	statsCmd.Flags().StringVar(&statsPeriodP, "period", string(stats.Month), "group commits by day, week, month or year")
+}
+
+var shortlogCmd = &cobra.Command{
+	Use:   "shortlog [<revision range>...]",
+	Short: "summarize history by author",
+	Long:  "list subjects of commits grouped by author, oldest first, taking the same revision ranges as log",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		commits := rangeCommits(args)
+		subjects := make(map[string][]string)
+		var authors []string
+		for i := len(commits) - 1; i >= 0; i-- {
+			c := commits[i]
+			author := c.Author.Name
+			if shortlogEmailP {
+				author = c.Author.Identity.String()
+			}
+			if _, ok := subjects[author]; !ok {
+				authors = append(authors, author)
+			}
+			subjects[author] = append(subjects[author], c.Subject())
+		}
+		sort.Strings(authors)
+		if shortlogNumberedP {
+			sort.SliceStable(authors, func(i, j int) bool {
+				return len(subjects[authors[i]]) > len(subjects[authors[j]])
+			})
+		}
+		for _, author := range authors {
+			if shortlogSummaryP {
+				fmt.Printf("%6d\t%s\n", len(subjects[author]), author)
+				continue
+			}
+			fmt.Printf("%s (%d):\n", author, len(subjects[author]))
+			for _, subject := range subjects[author] {
+				fmt.Printf("      %s\n", subject)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [<revision range>...]",
+	Short: "show contribution statistics",
+	Long: "count commits and lines added and removed by every author and in every period of time, " +
+		"taking the same revision ranges as log",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		period, err := stats.ParsePeriod(statsPeriodP)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report, err := stats.Collect(rangeCommits(args), period)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Authors:")
+		writeStatGroups(report.Authors)
+		fmt.Printf("\nPer %s:\n", period)
+		writeStatGroups(report.Periods)
+		fmt.Printf("\nTotal: %s\n", formatCounts(report.Total))
+	},
+}
+
+// rangeCommits returns commits of the revision ranges, the same way log does
+func rangeCommits(args []string) []commit.Commit {
+	include, exclude, err := parseLogRange(args)
+	if errors.Is(err, commit.ErrNoHead) {
+		return nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	commits, err := commit.LogRange(include, exclude)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return commits
+}
+
+func writeStatGroups(groups []stats.Group) {
+	width := 0
+	for _, g := range groups {
+		if len(g.Name) > width {
+			width = len(g.Name)
+		}
+	}
+	for _, g := range groups {
+		fmt.Printf("  %-*s  %s\n", width, g.Name, formatCounts(g.Counts))
+	}
+}
+
+func formatCounts(c stats.Counts) string {
+	noun := "commits"
+	if c.Commits == 1 {
+		noun = "commit"
+	}
+	return fmt.Sprintf("%d %s, +%d -%d", c.Commits, noun, c.Added, c.Removed)
+}
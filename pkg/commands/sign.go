@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/config"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/identity"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/signing"
+	"github.com/spf13/cobra"
+)
+
+// configuration options of commit signing: path to the private key used
+// by commit -S, and path to the file with keys whose signatures are trusted
+const (
+	signingKeyOption  = "signing.key"
+	trustedKeysOption = "signing.trustedKeys"
+)
+
+var defaultTrustedKeys = filepath.Join(constants.GitDir, "trusted_keys")
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+	rootCmd.AddCommand(verifyCommitCmd)
+}
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen <path>",
+	Short: "generate a key for signing commits",
+	Long: "write a new Ed25519 private key to the file and print the public key, " +
+		"in the form it's added to the trusted keys. Set " + signingKeyOption +
+		" option to the path of the file to sign commits with the key",
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		public, err := signing.GenerateKey(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s %s\n", signing.EncodeKey(public), identity.Current())
+	},
+}
+
+var verifyCommitCmd = &cobra.Command{
+	Use:   "verify-commit <commit>...",
+	Short: "check signatures of commits",
+	Long: "check that commits are signed by one of the keys listed in the trusted keys file, " +
+		"set by " + trustedKeysOption + " option, " + defaultTrustedKeys + " by default",
+	Args: cobra.MinimumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		trusted, err := trustedKeys()
+		if err != nil {
+			log.Fatal(err)
+		}
+		failed := false
+		for _, rev := range args {
+			c, err := revision.ResolveCommit(rev)
+			if err != nil {
+				log.Fatal(err)
+			}
+			status, err := describeSignature(c, trusted)
+			fmt.Printf("%s: %s\n", c.OID.String()[:7], status)
+			if err != nil {
+				failed = true
+			}
+		}
+		if failed {
+			log.Fatal("signature verification failed")
+		}
+	},
+}
+
+func signingKey() (ed25519.PrivateKey, error) {
+	path, err := config.Path(signingKeyOption, "")
+	if errors.Is(err, config.ErrNotSet) || path == "" {
+		return nil, fmt.Errorf("no signing key, set %s option", signingKeyOption)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signing.LoadPrivateKey(path)
+}
+
+func trustedKeys() ([]signing.TrustedKey, error) {
+	path, err := config.Path(trustedKeysOption, defaultTrustedKeys)
+	if err != nil {
+		return nil, err
+	}
+	return signing.ReadTrustedKeys(path)
+}
+
+// describeSignature returns human-readable result of the commit signature
+// verification, with non-nil error unless it's good
+func describeSignature(c commit.Commit, trusted []signing.TrustedKey) (string, error) {
+	key, err := c.VerifySignature(trusted)
+	switch {
+	case err == nil:
+		return fmt.Sprintf("Good signature from %s (%s)", key.Name, signing.EncodeKey(key.Key)), nil
+	case errors.Is(err, signing.ErrUntrusted):
+		return fmt.Sprintf("Good signature from untrusted key %s", signing.EncodeKey(key.Key)), err
+	case errors.Is(err, signing.ErrUnsigned):
+		return "No signature", err
+	default:
+		return "BAD signature", err
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/stash"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stashMessageP string
+	stashPatchP   bool
+)
+
+func init() {
+	rootCmd.AddCommand(stashCmd)
+	stashCmd.AddCommand(stashPushCmd, stashListCmd, stashShowCmd, stashApplyCmd, stashPopCmd, stashDropCmd)
+	stashPushCmd.Flags().StringVarP(&stashMessageP, "message", "m", "", "description of the stashed changes")
+	stashShowCmd.Flags().BoolVarP(&stashPatchP, "patch", "p", false, "show changes as a patch")
+	for _, cmd := range []*cobra.Command{stashApplyCmd, stashPopCmd} {
+		cmd.Flags().BoolVar(&noVerifyP, "no-verify", false, "skip pre-merge hook")
+	}
+}
+
+var stashCmd = &cobra.Command{
+	Use:   "stash",
+	Short: "put away changes in the working tree",
+	Long:  "save uncommitted changes on a stack of stash entries and reset working tree to HEAD, so that they can be restored later",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		stashPushCmd.Run(cmd, args)
+	},
+}
+
+var stashPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "save changes on the stash stack",
+	Long:  "save uncommitted changes as a new stash entry and reset working tree to HEAD",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := stash.Push(stashMessageP)
+		if errors.Is(err, stash.ErrNoLocalChanges) {
+			fmt.Println("No local changes to save")
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var stashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list stash entries",
+	Long:  "list stash entries, the most recent first",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := stash.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, entry := range entries {
+			fmt.Printf("stash@{%d}: %s\n", i, entry.Message)
+		}
+	},
+}
+
+var stashShowCmd = &cobra.Command{
+	Use:   "show [stash]",
+	Short: "show changes saved in a stash entry",
+	Long:  "list files changed in the stash entry (the most recent by default) relative to the commit it was made on",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		stashed, err := stash.Get(parseStashIndex(args))
+		if err != nil {
+			log.Fatal(err)
+		}
+		base, err := commit.GetCommit(stashed.Parent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		changes, err := diff.Trees(base.Tree, stashed.Tree)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if stashPatchP {
+			err = diff.WritePatch(os.Stdout, changes)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		for _, change := range changes {
+			fmt.Printf("%s\t%s\n", change.Status(), change.Path)
+		}
+	},
+}
+
+var stashApplyCmd = &cobra.Command{
+	Use:   "apply [stash]",
+	Short: "restore changes saved in a stash entry",
+	Long:  "merge changes of the stash entry (the most recent by default) into the working tree, keeping the entry",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		n := parseStashIndex(args)
+		runPreMergeHook("stash")
+		checkStashApplied(stash.Apply(n))
+	},
+}
+
+var stashPopCmd = &cobra.Command{
+	Use:   "pop [stash]",
+	Short: "restore changes saved in a stash entry and drop it",
+	Long:  "merge changes of the stash entry (the most recent by default) into the working tree and remove the entry, unless there were conflicts",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		n := parseStashIndex(args)
+		runPreMergeHook("stash")
+		checkStashApplied(stash.Pop(n))
+	},
+}
+
+var stashDropCmd = &cobra.Command{
+	Use:   "drop [stash]",
+	Short: "remove a stash entry",
+	Long:  "remove the stash entry (the most recent by default) from the stack",
+	Args:  cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		err := stash.Drop(parseStashIndex(args))
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// parseStashIndex returns index of the stash entry given either as stash@{n}
+// or just n in the arguments, the most recent entry if none is given
+func parseStashIndex(args []string) int {
+	if len(args) == 0 {
+		return 0
+	}
+	arg := args[0]
+	if strings.HasPrefix(arg, "stash@{") && strings.HasSuffix(arg, "}") {
+		arg = arg[len("stash@{") : len(arg)-1]
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		log.Fatalf("invalid stash entry %s", args[0])
+	}
+	return n
+}
+
+func checkStashApplied(err error) {
+	var conflict commit.ConflictError
+	if errors.As(err, &conflict) {
+		for _, path := range conflict.Paths {
+			fmt.Printf("CONFLICT: %s\n", path)
+		}
+		log.Fatal("fix conflicts in the working tree, the stash entry is kept")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
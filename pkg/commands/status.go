@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/status"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	porcelainP    bool
+	statusBranchP bool
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&porcelainP, "porcelain", false, "machine-readable output, one \"XY path\" line per file")
+	statusCmd.Flags().BoolVarP(&statusBranchP, "branch", "b", false, "show branch and ahead/behind counts in the porcelain format")
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show the working tree status",
+	Long: "show changes staged in the index, changes of the working tree that are not staged and untracked files. " +
+		"Without an index all changes are to be committed. The branch is compared to the revision set by " +
+		"branch.<name>.upstream option",
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		st, err := status.Get()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if porcelainP {
+			writePorcelainStatus(st)
+			return
+		}
+		writeStatus(st)
+	},
+}
+
+// writePorcelainStatus writes status in the format of git status --porcelain:
+// staged and unstaged status letters followed by the path, ?? for untracked files
+func writePorcelainStatus(st status.Status) {
+	if statusBranchP {
+		fmt.Println("## " + porcelainBranch(st.Branch))
+	}
+	codes := make(map[string][]byte)
+	mark := func(changes []diff.Change, i int) {
+		for _, change := range changes {
+			if codes[change.Path] == nil {
+				codes[change.Path] = []byte("  ")
+			}
+			codes[change.Path][i] = change.Status()[0]
+		}
+	}
+	mark(st.Staged, 0)
+	mark(st.Unstaged, 1)
+	paths := make([]string, 0, len(codes))
+	for path := range codes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("%s %s\n", codes[path], path)
+	}
+	for _, path := range st.Untracked {
+		fmt.Printf("?? %s\n", path)
+	}
+}
+
+func porcelainBranch(b status.Branch) string {
+	var line string
+	switch {
+	case b.Head == storage.ZeroOID && b.Name != "":
+		line = "No commits yet on " + b.Name
+	case b.Name == "":
+		line = "HEAD (no branch)"
+	default:
+		line = b.Name
+	}
+	if b.Upstream == "" {
+		return line
+	}
+	line += "..." + b.Upstream
+	var counts []string
+	if b.Ahead > 0 {
+		counts = append(counts, fmt.Sprintf("ahead %d", b.Ahead))
+	}
+	if b.Behind > 0 {
+		counts = append(counts, fmt.Sprintf("behind %d", b.Behind))
+	}
+	if len(counts) > 0 {
+		line += " [" + strings.Join(counts, ", ") + "]"
+	}
+	return line
+}
+
+func writeStatus(st status.Status) {
+	b := st.Branch
+	if b.Name != "" {
+		fmt.Printf("On branch %s\n", b.Name)
+	} else if b.Head != storage.ZeroOID {
+		fmt.Printf("HEAD detached at %s\n", b.Head.String()[:7])
+	}
+	if b.Upstream != "" {
+		fmt.Println(upstreamSummary(b))
+	}
+	if b.Head == storage.ZeroOID {
+		fmt.Println("\nNo commits yet")
+	}
+	writeChanges("Changes to be committed:", st.Staged)
+	writeChanges("Changes not staged for commit:", st.Unstaged)
+	if len(st.Untracked) > 0 {
+		fmt.Println("\nUntracked files:")
+		for _, path := range st.Untracked {
+			fmt.Printf("\t%s\n", path)
+		}
+	}
+	switch {
+	case st.Clean():
+		fmt.Println("\nnothing to commit, working tree clean")
+	case len(st.Staged) == 0 && len(st.Unstaged) > 0:
+		fmt.Println("\nno changes added to commit (use \"gitik add\")")
+	case len(st.Staged) == 0:
+		fmt.Println("\nnothing added to commit but untracked files present (use \"gitik add\" to track)")
+	}
+}
+
+func writeChanges(title string, changes []diff.Change) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Println("\n" + title)
+	for _, change := range changes {
+		fmt.Printf("\t%-12s%s\n", changeLabels[change.Status()]+":", change.Path)
+	}
+}
+
+func upstreamSummary(b status.Branch) string {
+	switch {
+	case b.Ahead > 0 && b.Behind > 0:
+		return fmt.Sprintf("Your branch and '%s' have diverged,\nand have %d and %d different commits each, respectively.",
+			b.Upstream, b.Ahead, b.Behind)
+	case b.Ahead > 0:
+		return fmt.Sprintf("Your branch is ahead of '%s' by %s.", b.Upstream, plural(b.Ahead, "commit"))
+	case b.Behind > 0:
+		return fmt.Sprintf("Your branch is behind '%s' by %s.", b.Upstream, plural(b.Behind, "commit"))
+	default:
+		return fmt.Sprintf("Your branch is up to date with '%s'.", b.Upstream)
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
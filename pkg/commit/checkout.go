@@ -0,0 +1,554 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+type CheckoutError struct {
+	origError    error
+	recoverError error
+}
+
+func (ce CheckoutError) Error() string {
+	if ce.origError != nil {
+		msg := fmt.Sprintf("failed to update working tree: %s", ce.origError)
+		if ce.recoverError != nil {
+			msg = fmt.Sprintf("failed to recover: %s, original error: %s", ce.recoverError, msg)
+		}
+		return msg
+	}
+	return ""
+}
+
+// DirtyTreeError is returned when updating working tree would overwrite
+// local changes to the files, that is changes of the working tree since HEAD
+type DirtyTreeError struct {
+	Paths []string
+}
+
+func (dte DirtyTreeError) Error() string {
+	return fmt.Sprintf("local changes would be overwritten: %s", strings.Join(dte.Paths, ", "))
+}
+
+// UntrackedFilesError is returned when updating working tree would overwrite
+// files that are not tracked, that is missing from the tree of HEAD
+type UntrackedFilesError struct {
+	Paths []string
+}
+
+func (ufe UntrackedFilesError) Error() string {
+	return fmt.Sprintf("untracked files would be overwritten: %s", strings.Join(ufe.Paths, ", "))
+}
+
+// ErrCheckoutInterrupted is returned when checking out while the journal
+// of an interrupted checkout is present
+var ErrCheckoutInterrupted = errors.New("previous checkout was interrupted, continue or abort it first")
+
+// ErrNoCheckout is returned when continuing or aborting checkout, but
+// there is no interrupted one
+var ErrNoCheckout = errors.New("no checkout in progress")
+
+// journalName is filename of the checkout journal in the git directory.
+// It is written before working tree is changed and removed once HEAD is
+// updated, so its presence means checkout was interrupted. It holds the
+// tree the working tree is changed from, the tree it is changed to, the
+// commits HEAD is moved from and to, and the new tree of the index if
+// repository has one, a "<name> <oid>" line each
+const journalName = "CHECKOUT_JOURNAL"
+
+// journal is the state of the checkout in progress
+type journal struct {
+	From, To       storage.OID
+	Previous, Next storage.OID
+	Index          storage.OID
+}
+
+// Checkout sets working tree to the tree of the commit and detaches HEAD at
+// it. Local changes are carried over to the new working tree, unless the
+// file is different in the commit, then DirtyTreeError is returned and
+// nothing is changed. If force is set, local changes are discarded instead.
+// Untracked files are kept, even if force is set. Checkout fails with
+// UntrackedFilesError if the commit has files in their place, unless forced.
+// Changes staged in the index are carried over the same way.
+// Only the files that change are written. If that fails, previous state of
+// the working tree is restored. Should the process be killed mid-way,
+// checkout can be finished with ContinueCheckout, or undone with AbortCheckout
+func (c Commit) Checkout(force bool) error {
+	if CheckoutInProgress() {
+		return ErrCheckoutInterrupted
+	}
+	head, err := GetHead()
+	if err != nil {
+		return err
+	}
+	workingFiles, err := plumbing.HashTreeFiles(".")
+	if err != nil {
+		return err
+	}
+	tree, err := carryChanges(head.Tree, workingFiles, c.Tree, force)
+	if err != nil {
+		return err
+	}
+	working, err := workingTree(workingFiles, tree)
+	if err != nil {
+		return err
+	}
+	j := journal{From: working, To: tree, Previous: head.OID, Next: c.OID}
+	j.Index, err = carryStaged(head.Tree, c.Tree, force)
+	if err != nil {
+		return err
+	}
+	err = writeJournal(j)
+	if err != nil {
+		return err
+	}
+	err = plumbing.UpdateTree(working, tree)
+	if err != nil {
+		finalError := CheckoutError{origError: err}
+		finalError.recoverError = AbortCheckout()
+		return finalError
+	}
+	return finishCheckout(j)
+}
+
+// CheckoutInProgress reports whether checkout was interrupted
+func CheckoutInProgress() bool {
+	_, err := os.Stat(journalPath())
+	return err == nil
+}
+
+// ContinueCheckout finishes the interrupted checkout
+func ContinueCheckout() error {
+	j, err := readJournal()
+	if err != nil {
+		return err
+	}
+	err = plumbing.UpdateTree(j.From, j.To)
+	if err != nil {
+		return err
+	}
+	return finishCheckout(j)
+}
+
+// AbortCheckout restores working tree to the state it had before
+// the interrupted checkout. HEAD is not moved until checkout is finished,
+// so it's left as it is
+func AbortCheckout() error {
+	j, err := readJournal()
+	if err != nil {
+		return err
+	}
+	err = plumbing.UpdateTree(j.To, j.From)
+	if err != nil {
+		return err
+	}
+	return os.Remove(journalPath())
+}
+
+func finishCheckout(j journal) error {
+	if j.Index != storage.ZeroOID {
+		err := index.Reset(j.Index)
+		if err != nil {
+			return err
+		}
+	}
+	reason := fmt.Sprintf("checkout: moving from %s to %s", j.Previous, j.Next)
+	err := refs.Detach(j.Next, reason)
+	if err != nil {
+		return err
+	}
+	return os.Remove(journalPath())
+}
+
+func writeJournal(j journal) error {
+	data := fmt.Sprintf("from %s\nto %s\nprevious %s\nnext %s\n", j.From, j.To, j.Previous, j.Next)
+	if j.Index != storage.ZeroOID {
+		data += fmt.Sprintf("index %s\n", j.Index)
+	}
+	return storage.WriteFile(journalPath(), []byte(data))
+}
+
+func readJournal() (journal, error) {
+	data, err := ioutil.ReadFile(journalPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return journal{}, ErrNoCheckout
+	}
+	if err != nil {
+		return journal{}, err
+	}
+	var j journal
+	fields := map[string]*storage.OID{"from": &j.From, "to": &j.To, "previous": &j.Previous, "next": &j.Next, "index": &j.Index}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		field, ok := fields[parts[0]]
+		if !ok || len(parts) != 2 {
+			return journal{}, fmt.Errorf("%s: malformed line %q", journalName, line)
+		}
+		*field, err = storage.MakeOID([]byte(parts[1]))
+		if err != nil {
+			return journal{}, fmt.Errorf("%s: %w", journalName, err)
+		}
+	}
+	return j, nil
+}
+
+func journalPath() string {
+	return filepath.Join(constants.GitDir, journalName)
+}
+
+// UpdateWorkingTree changes working tree and the index, if any, from HEAD
+// to the tree, writing only the files that differ. Local changes and untracked
+// files are carried over the way Checkout does, and DirtyTreeError or
+// UntrackedFilesError is returned before anything is changed if they would be
+// overwritten. If force is set, local changes to the tracked files, the ones in
+// HEAD, the index or the conflicted merge, are discarded and untracked files kept
+func UpdateWorkingTree(tree storage.OID, force bool) error {
+	return updateWorkingTree(tree, tree, force)
+}
+
+// UpdateWorkingTreeAndIndex is UpdateWorkingTree that sets the index to
+// indexTree instead of tree, carrying changes staged in it over the same way
+func UpdateWorkingTreeAndIndex(tree, indexTree storage.OID) error {
+	return updateWorkingTree(tree, indexTree, false)
+}
+
+func updateWorkingTree(tree, indexTree storage.OID, force bool) error {
+	head, err := GetHead()
+	if err != nil && !errors.Is(err, ErrNoHead) {
+		return err
+	}
+	base := head.Tree
+	if force {
+		base, err = trackedTree(head.Tree)
+		if err != nil {
+			return err
+		}
+	}
+	workingFiles, err := plumbing.HashTreeFiles(".")
+	if err != nil {
+		return err
+	}
+	result, err := carryChanges(base, workingFiles, tree, force)
+	if err != nil {
+		return err
+	}
+	staged, err := carryStaged(head.Tree, indexTree, force)
+	if err != nil {
+		return err
+	}
+	working, err := workingTree(workingFiles, result)
+	if err != nil {
+		return err
+	}
+	err = plumbing.UpdateTree(working, result)
+	if err != nil {
+		finalError := CheckoutError{origError: err}
+		finalError.recoverError = plumbing.UpdateTree(result, working)
+		return finalError
+	}
+	if staged == storage.ZeroOID {
+		return nil
+	}
+	return index.Reset(staged)
+}
+
+// LocalChanges returns paths of the tracked files that differ from HEAD,
+// in the working tree or in the index. Untracked files are not included
+func LocalChanges() ([]string, error) {
+	head, err := GetHead()
+	if err != nil && !errors.Is(err, ErrNoHead) {
+		return nil, err
+	}
+	headFiles, err := plumbing.ReadTreeFiles(head.Tree)
+	if err != nil {
+		return nil, err
+	}
+	workingFiles, err := plumbing.HashTreeFiles(".")
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for path, oid := range headFiles {
+		if workingFiles[path] != oid {
+			changed[path] = true
+		}
+	}
+	idx, err := index.Read()
+	if err != nil && !errors.Is(err, index.ErrNoIndex) {
+		return nil, err
+	}
+	if idx != nil {
+		for path, oid := range idx.Files() {
+			if headFiles[path] != oid {
+				changed[path] = true
+			}
+		}
+		for path := range headFiles {
+			if _, ok := idx.Get(path); !ok {
+				changed[path] = true
+			}
+		}
+	}
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// TrackedWorkingTree writes tree of the tracked files, the ones in HEAD,
+// the index or the conflicted merge, as they are in the working tree.
+// Untracked files are left out
+func TrackedWorkingTree() (storage.OID, error) {
+	head, err := GetHead()
+	if err != nil && !errors.Is(err, ErrNoHead) {
+		return storage.ZeroOID, err
+	}
+	tracked, err := trackedFiles(head.Tree)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	files := make(map[string]storage.OID)
+	for path := range tracked {
+		name := filepath.FromSlash(path)
+		info, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		files[path], err = plumbing.WriteFile(name)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	return plumbing.WriteTreeFiles(files)
+}
+
+// trackedTree returns tree with the files of HEAD tree together with
+// the files of the index and of the operation stopped by conflicts,
+// which are tracked as well
+func trackedTree(head storage.OID) (storage.OID, error) {
+	files, err := trackedFiles(head)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return plumbing.WriteTreeFiles(files)
+}
+
+// trackedFiles returns files of trackedTree, keyed by path
+func trackedFiles(head storage.OID) (map[string]storage.OID, error) {
+	pending, err := pendingTree()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := index.Read()
+	if err != nil && !errors.Is(err, index.ErrNoIndex) {
+		return nil, err
+	}
+	files, err := plumbing.ReadTreeFiles(head)
+	if err != nil {
+		return nil, err
+	}
+	tracked := []map[string]storage.OID{}
+	if idx != nil {
+		tracked = append(tracked, idx.Files())
+	}
+	if pending != storage.ZeroOID {
+		pendingFiles, err := plumbing.ReadTreeFiles(pending)
+		if err != nil {
+			return nil, err
+		}
+		tracked = append(tracked, pendingFiles)
+	}
+	for _, more := range tracked {
+		for name, oid := range more {
+			if _, ok := files[name]; !ok {
+				files[name] = oid
+			}
+		}
+	}
+	return files, nil
+}
+
+// workingTree writes tree of the working tree files, given by their hashes.
+// Only the files that differ in target are stored: those are going to be
+// overwritten or removed, and may have to be restored from the tree
+func workingTree(workingFiles map[string]storage.OID, target storage.OID) (storage.OID, error) {
+	targetFiles, err := plumbing.ReadTreeFiles(target)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	for path, oid := range workingFiles {
+		if targetOID, ok := targetFiles[path]; ok && targetOID == oid {
+			continue
+		}
+		_, err := plumbing.WriteFile(filepath.FromSlash(path))
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	return plumbing.WriteTreeFiles(workingFiles)
+}
+
+// carryChanges applies local changes, the difference between base tree and
+// the working tree files, to the target tree. Return id of the resulting tree, or
+// DirtyTreeError if some of the changed files are different in the target.
+// Untracked files, the ones missing from base, are never dropped, unless
+// target has a file in their place, which is UntrackedFilesError.
+// If force is set, local changes to the tracked files are dropped, and
+// untracked files are overwritten without an error
+func carryChanges(base storage.OID, workingFiles map[string]storage.OID, target storage.OID, force bool) (storage.OID, error) {
+	baseFiles, err := plumbing.ReadTreeFiles(base)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	targetFiles, err := plumbing.ReadTreeFiles(target)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	changed := make(map[string]bool)
+	for path, oid := range workingFiles {
+		if base, ok := baseFiles[path]; !ok || base != oid {
+			changed[path] = true
+		}
+	}
+	for path := range baseFiles {
+		if _, ok := workingFiles[path]; !ok {
+			changed[path] = true
+		}
+	}
+	var conflicts, untracked []string
+	for path := range changed {
+		workingOID, inWorking := workingFiles[path]
+		baseOID, inBase := baseFiles[path]
+		targetOID, inTarget := targetFiles[path]
+		if inWorking == inTarget && workingOID == targetOID {
+			// the change is already in the target
+			continue
+		}
+		if !inBase {
+			if inTarget || collides(path, targetFiles) {
+				if !force {
+					untracked = append(untracked, path)
+				}
+				continue
+			}
+			targetFiles[path] = workingOID
+			continue
+		}
+		if force {
+			continue
+		}
+		if baseOID != targetOID || !inTarget {
+			conflicts = append(conflicts, path)
+			continue
+		}
+		if inWorking {
+			targetFiles[path] = workingOID
+		} else {
+			delete(targetFiles, path)
+		}
+	}
+	if len(untracked) > 0 {
+		sort.Strings(untracked)
+		return storage.ZeroOID, UntrackedFilesError{Paths: untracked}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return storage.ZeroOID, DirtyTreeError{Paths: conflicts}
+	}
+	return plumbing.WriteTreeFiles(targetFiles)
+}
+
+// carryStaged applies changes staged in the index, the difference between
+// base tree and the index, to the target tree. Return id of the resulting
+// tree, or zero OID if repository has no index. Changes to the files that are
+// different in the target are DirtyTreeError, unless force is set, then
+// they are dropped
+func carryStaged(base, target storage.OID, force bool) (storage.OID, error) {
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		return storage.ZeroOID, nil
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if force {
+		return target, nil
+	}
+	baseFiles, err := plumbing.ReadTreeFiles(base)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	targetFiles, err := plumbing.ReadTreeFiles(target)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	stagedFiles := idx.Files()
+	changed := make(map[string]bool)
+	for path, oid := range stagedFiles {
+		if base, ok := baseFiles[path]; !ok || base != oid {
+			changed[path] = true
+		}
+	}
+	for path := range baseFiles {
+		if _, ok := stagedFiles[path]; !ok {
+			changed[path] = true
+		}
+	}
+	var conflicts []string
+	for path := range changed {
+		stagedOID, staged := stagedFiles[path]
+		baseOID, inBase := baseFiles[path]
+		targetOID, inTarget := targetFiles[path]
+		switch {
+		case staged == inTarget && stagedOID == targetOID:
+			// the change is already in the target
+		case inBase != inTarget || baseOID != targetOID || (!inBase && collides(path, targetFiles)):
+			conflicts = append(conflicts, path)
+		case staged:
+			targetFiles[path] = stagedOID
+		default:
+			delete(targetFiles, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return storage.ZeroOID, DirtyTreeError{Paths: conflicts}
+	}
+	return plumbing.WriteTreeFiles(targetFiles)
+}
+
+// collides reports whether the path cannot be added to the files because
+// one of its directories is a file there, or it is a directory there
+func collides(path string, files map[string]storage.OID) bool {
+	for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+		if _, ok := files[dir]; ok {
+			return true
+		}
+	}
+	for name := range files {
+		if strings.HasPrefix(name, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -2,54 +2,230 @@ package commit
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
+	"strings"
 
-	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/identity"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
 	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/signing"
 	"github.com/i-hate-nicknames/gitik/pkg/storage"
 )
 
 // Commit represents a version control commit. It's a snapshot
 // of repository together with a message and link to previous commit
 type Commit struct {
-	OID     storage.OID
-	Tree    storage.OID
-	Parent  storage.OID
-	Message string
+	OID  storage.OID
+	Tree storage.OID
+	// Parent is the first parent, the only one gitik follows. OtherParents
+	// are the rest of the parents of merge commits made by other tools
+	Parent       storage.OID
+	OtherParents []storage.OID
+	// Author is who originally made the changes, Committer is who
+	// created this commit object, they differ e.g. for cherry-picked commits
+	Author    identity.Signature
+	Committer identity.Signature
+	// Signature is made over the commit encoded without the signature
+	// itself, see Payload. Empty for unsigned commits
+	Signature string
+	// ExtraHeaders are headers gitik does not know about, kept so that
+	// commits made by other tools can be encoded back exactly
+	ExtraHeaders []Header
+	Message      string
+	// headerOrder is the order of header keys of the decoded commit
+	headerOrder []string
 }
 
-// SaveCurrentTree saves current working tree to the datastore, and creates a
-// commit object that points to that tree. Additionally, it advances HEAD of
-// the repository and point it to the fresly created commit
+// Header is a single header of the encoded commit. Values may span several
+// lines, every line after the first is encoded with a leading space
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Options control how SaveCurrentTree makes the commit
+type Options struct {
+	// SigningKey is used to sign the commit, if set
+	SigningKey ed25519.PrivateKey
+	// Amend replaces HEAD commit instead of adding a new one on top of it
+	Amend bool
+	// AllowEmpty allows commits that change nothing relative to their parent
+	AllowEmpty bool
+}
+
+// EmptyCommitError is returned when a commit would change nothing
+// relative to its parent, i.e. have the same tree
+type EmptyCommitError struct {
+	Tree storage.OID
+}
+
+func (ece EmptyCommitError) Error() string {
+	return "nothing to commit, working tree clean"
+}
+
+// SaveCurrentTree saves current tree, the index or the working tree if there
+// is no index, to the datastore, and creates a commit object that points
+// to that tree. Additionally, it advances HEAD of the repository and point it
+// to the fresly created commit.
+// When amending, the new commit takes place of the HEAD commit, keeping its
+// parents and author, and empty message means the message is reused
 // Return new commit's storage ID
-func SaveCurrentTree(message string) (storage.OID, error) {
-	oid, err := plumbing.WriteTree(".")
+func SaveCurrentTree(message string, opts Options) (storage.OID, error) {
+	oid, err := CurrentTree()
 	if err != nil {
 		return storage.ZeroOID, err
 	}
 	c := Commit{Tree: oid, Message: message}
-	headOID, err := getHeadOID()
-	if err != nil && !errors.Is(err, ErrNoHead) {
+	action := "commit"
+	head, err := GetHead()
+	switch {
+	case opts.Amend && err != nil:
+		return storage.ZeroOID, fmt.Errorf("nothing to amend: %w", err)
+	case opts.Amend:
+		c.Parent = head.Parent
+		c.OtherParents = head.OtherParents
+		c.Author = head.Author
+		if c.Message == "" {
+			c.Message = head.Message
+		}
+		action = "commit (amend)"
+	case err == nil:
+		c.Parent = head.OID
+	case !errors.Is(err, ErrNoHead):
 		return storage.ZeroOID, err
 	}
-	if err == nil {
-		c.Parent = headOID
+	if !opts.AllowEmpty {
+		err = c.checkEmpty()
+		if err != nil {
+			return storage.ZeroOID, err
+		}
 	}
-	commitOID, err := storage.StoreObject(c.Encode(), storage.TypeCommit)
+	pick, err := pendingPick()
 	if err != nil {
 		return storage.ZeroOID, err
 	}
-	err = SetHead(commitOID)
+	if pick != storage.ZeroOID && !opts.Amend {
+		// concluding a cherry-pick stopped by conflicts, keep the original author
+		picked, err := GetCommit(pick)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		c.Author = picked.Author
+	}
+	if opts.SigningKey != nil {
+		c.Sign(opts.SigningKey)
+	}
+	commitOID, err := c.Save(action)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return commitOID, ClearPending()
+}
+
+// CurrentTree writes the tree that would be committed: the files of the
+// index, or the whole working tree if repository has no index
+func CurrentTree() (storage.OID, error) {
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		return plumbing.WriteTree(".")
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return idx.WriteTree()
+}
+
+// CurrentFiles returns files of the tree CurrentTree would write, keyed by
+// path. Working tree files are only hashed, nothing is stored
+func CurrentFiles() (map[string]storage.OID, error) {
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		return plumbing.HashTreeFiles(".")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idx.Files(), nil
+}
+
+// checkEmpty returns EmptyCommitError if the commit has the same tree as its
+// parent, or no files at all if it's the first commit
+func (c Commit) checkEmpty() error {
+	parentTree, err := c.parentTree()
+	if err != nil {
+		return err
+	}
+	if c.Tree == parentTree {
+		return EmptyCommitError{Tree: c.Tree}
+	}
+	if parentTree == storage.ZeroOID {
+		files, err := plumbing.ReadTreeFiles(c.Tree)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return EmptyCommitError{Tree: c.Tree}
+		}
+	}
+	return nil
+}
+
+// Save stores commit in the object database and advances HEAD to it, recording
+// action that created the commit in the reflog
+func (c Commit) Save(action string) (storage.OID, error) {
+	commitOID, err := c.Write()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if action == "commit" && c.Parent == storage.ZeroOID {
+		action += " (initial)"
+	}
+	err = SetHead(commitOID, action+": "+c.Subject())
 	if err != nil {
 		return storage.ZeroOID, fmt.Errorf("make commit: cannot write commit to head: %w", err)
 	}
 	return commitOID, nil
 }
 
+// Write stores commit in the object database, without moving HEAD.
+// Committer defaults to the current user, author defaults to the committer
+func (c Commit) Write() (storage.OID, error) {
+	c.setIdentities()
+	return storage.StoreObject(c.Encode(), storage.TypeCommit)
+}
+
+// Sign the commit with the key. Committer and author are filled in
+// first, as they are a part of the signed data
+func (c *Commit) Sign(key ed25519.PrivateKey) {
+	c.setIdentities()
+	c.Signature = signing.Sign(c.Payload(), key)
+}
+
+// VerifySignature checks that the commit is signed by one of the trusted keys,
+// and returns the key that signed it
+func (c Commit) VerifySignature(trusted []signing.TrustedKey) (signing.TrustedKey, error) {
+	return signing.Verify(c.Payload(), c.Signature, trusted)
+}
+
+// Payload returns the data covered by the commit signature,
+// that is the commit encoded without the signature
+func (c Commit) Payload() []byte {
+	c.Signature = ""
+	return c.Encode()
+}
+
+func (c *Commit) setIdentities() {
+	if c.Committer == (identity.Signature{}) {
+		c.Committer = identity.Now()
+	}
+	if c.Author == (identity.Signature{}) {
+		c.Author = c.Committer
+	}
+}
+
 // Log returns all commits that were made starting from HEAD
 // and until the first commit, following the parent chain
 func Log() ([]Commit, error) {
@@ -76,50 +252,163 @@ func LogFrom(startFrom storage.OID) ([]Commit, error) {
 }
 
 // Encode commit to byte sequence. This data can be later be used with
-// Decode method to retrieve commit back
+// Decode method to retrieve commit back. Headers of a decoded commit keep
+// the order they were decoded in, so that commits made by other tools are
+// encoded back exactly. New commits, and headers the decoded commit did
+// not have, follow the order: tree, parents, author, committer,
+// extra headers, signature
 func (c Commit) Encode() []byte {
 	var buf bytes.Buffer
-	buf.WriteString(fmt.Sprintf("tree %s\n", c.Tree))
-	if c.Parent != storage.ZeroOID {
-		buf.WriteString(fmt.Sprintf("parent %s\n", c.Parent))
+	for _, h := range orderHeaders(c.headers(), c.headerOrder) {
+		writeHeader(&buf, h.Key, h.Value)
 	}
 	buf.WriteString("\n" + c.Message + "\n")
 	return buf.Bytes()
 }
 
+// headers returns headers of the commit in the default order
+func (c Commit) headers() []Header {
+	headers := []Header{{Key: "tree", Value: c.Tree.String()}}
+	if c.Parent != storage.ZeroOID {
+		headers = append(headers, Header{Key: "parent", Value: c.Parent.String()})
+	}
+	for _, parent := range c.OtherParents {
+		headers = append(headers, Header{Key: "parent", Value: parent.String()})
+	}
+	// commits made before authorship was recorded have no author and committer
+	if c.Author != (identity.Signature{}) {
+		headers = append(headers, Header{Key: "author", Value: c.Author.String()})
+	}
+	if c.Committer != (identity.Signature{}) {
+		headers = append(headers, Header{Key: "committer", Value: c.Committer.String()})
+	}
+	headers = append(headers, c.ExtraHeaders...)
+	if c.Signature != "" {
+		headers = append(headers, Header{Key: "signature", Value: c.Signature})
+	}
+	return headers
+}
+
+// orderHeaders puts headers in the order of given keys. Headers with the same
+// key keep their relative order, headers left over after that go last
+func orderHeaders(headers []Header, order []string) []Header {
+	used := make([]bool, len(headers))
+	var result []Header
+	for _, key := range order {
+		for i, h := range headers {
+			if !used[i] && h.Key == key {
+				used[i] = true
+				result = append(result, h)
+				break
+			}
+		}
+	}
+	for i, h := range headers {
+		if !used[i] {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key + " " + strings.ReplaceAll(value, "\n", "\n ") + "\n")
+}
+
+// Parents returns all parents of the commit, the first parent first
+func (c Commit) Parents() []storage.OID {
+	if c.Parent == storage.ZeroOID {
+		return nil
+	}
+	return append([]storage.OID{c.Parent}, c.OtherParents...)
+}
+
+// Subject returns the first line of the commit message
+func (c Commit) Subject() string {
+	return strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+}
+
+// Body returns the commit message without the subject line
+func (c Commit) Body() string {
+	parts := strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+func shortOID(oid storage.OID) string {
+	return oid.String()[:7]
+}
+
 // ErrInvalidEncoding signifies problems with commit encoding
 var ErrInvalidEncoding = errors.New("invalid encoding")
 
 // Decode data into a Commit
 func Decode(data []byte) (Commit, error) {
-	rawParts := bytes.Split(data, []byte("\n\n"))
+	// message itself may contain blank lines, only the first one separates it from the header
+	rawParts := bytes.SplitN(data, []byte("\n\n"), 2)
 	if len(rawParts) != 2 {
 		return Commit{}, ErrInvalidEncoding
 	}
 	header, message := rawParts[0], rawParts[1]
-	headerParts := bytes.Split(header, []byte("\n"))
-	result := Commit{Message: string(message)}
-	for _, line := range headerParts {
-		parts := bytes.Split(line, []byte(" "))
-		if len(parts) != 2 {
-			return Commit{}, ErrInvalidEncoding
-		}
-		oid, err := storage.MakeOID(parts[1])
-		if err != nil {
-			return Commit{}, err
-		}
-		switch string(parts[0]) {
+	headers, err := parseHeaders(header)
+	if err != nil {
+		return Commit{}, err
+	}
+	// Encode always terminates message with a newline
+	result := Commit{Message: string(bytes.TrimSuffix(message, []byte("\n")))}
+	for _, h := range headers {
+		result.headerOrder = append(result.headerOrder, h.Key)
+		var err error
+		switch h.Key {
 		case "tree":
-			result.Tree = oid
+			result.Tree, err = storage.MakeOID([]byte(h.Value))
 		case "parent":
-			result.Parent = oid
+			var parent storage.OID
+			parent, err = storage.MakeOID([]byte(h.Value))
+			if result.Parent == storage.ZeroOID {
+				result.Parent = parent
+			} else {
+				result.OtherParents = append(result.OtherParents, parent)
+			}
+		case "author":
+			result.Author, err = identity.ParseSignature(h.Value)
+		case "committer":
+			result.Committer, err = identity.ParseSignature(h.Value)
+		case "signature":
+			result.Signature = h.Value
 		default:
-			return Commit{}, ErrInvalidEncoding
+			result.ExtraHeaders = append(result.ExtraHeaders, h)
+		}
+		if err != nil {
+			return Commit{}, err
 		}
 	}
 	return result, nil
 }
 
+// parseHeaders splits header of the encoded commit into headers, joining
+// continuation lines, that start with a space, with the line before them
+func parseHeaders(header []byte) ([]Header, error) {
+	var headers []Header
+	for _, line := range bytes.Split(header, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(" ")) {
+			if len(headers) == 0 {
+				return nil, ErrInvalidEncoding
+			}
+			headers[len(headers)-1].Value += "\n" + string(line[1:])
+			continue
+		}
+		parts := bytes.SplitN(line, []byte(" "), 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, ErrInvalidEncoding
+		}
+		headers = append(headers, Header{Key: string(parts[0]), Value: string(parts[1])})
+	}
+	return headers, nil
+}
+
 // GetCommit gets commit by its ID
 func GetCommit(oid storage.OID) (Commit, error) {
 	obj, err := storage.GetObject(oid)
@@ -134,10 +423,10 @@ func GetCommit(oid storage.OID) (Commit, error) {
 	return commit, nil
 }
 
-// SetHead sets current HEAD of gitik to give oid
-func SetHead(oid storage.OID) error {
-	path := path.Join(constants.GitDir, constants.HeadName)
-	return storage.WriteFile(path, []byte(oid.String()))
+// SetHead sets current HEAD of gitik to given oid, moving the branch HEAD
+// points to, if any. The move is recorded in the reflog with given reason
+func SetHead(oid storage.OID, reason string) error {
+	return refs.Update(refs.Head, oid, reason)
 }
 
 // ErrNoHead is returned when repository has no HEAD
@@ -153,58 +442,55 @@ func GetHead() (Commit, error) {
 }
 
 func getHeadOID() (storage.OID, error) {
-	path := path.Join(constants.GitDir, constants.HeadName)
-	file, err := os.Open(path)
-	defer file.Close()
-	if errors.Is(err, os.ErrNotExist) {
-		return storage.ZeroOID, ErrNoHead
-	}
-	if err != nil {
-		return storage.ZeroOID, err
-	}
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		return storage.ZeroOID, err
-	}
-	if len(data) == 0 {
+	oid, err := refs.Read(refs.Head)
+	if errors.Is(err, refs.ErrNotFound) {
 		return storage.ZeroOID, ErrNoHead
 	}
-	return storage.MakeOID(data)
+	return oid, err
 }
 
-type CheckoutError struct {
-	origError    error
-	recoverError error
-}
+// ResetMode defines what is reset along with HEAD by Reset
+type ResetMode int
 
-func (ce CheckoutError) Error() string {
-	if ce.origError != nil {
-		msg := fmt.Sprintf("failed to read tree: %s", ce.origError)
-		if ce.recoverError != nil {
-			msg = fmt.Sprintf("failed to recover: %s, original error: %s", ce.recoverError, msg)
-		}
-		return msg
+const (
+	// ResetSoft only moves HEAD, leaving working tree intact
+	ResetSoft ResetMode = iota
+	// ResetMixed moves HEAD and resets the index, if there is one,
+	// leaving working tree intact
+	ResetMixed
+	// ResetHard moves HEAD and overwrites tracked files of the working tree
+	// and the index with the tree of the commit, leaving untracked files alone
+	ResetHard
+)
+
+func (m ResetMode) String() string {
+	switch m {
+	case ResetSoft:
+		return "soft"
+	case ResetMixed:
+		return "mixed"
+	case ResetHard:
+		return "hard"
+	default:
+		return "_unknown"
 	}
-	return ""
 }
 
-func (c Commit) Checkout(recover bool) error {
-	head, err := GetHead()
-	if err != nil {
-		return err
-	}
-	var finalError CheckoutError
-	err = plumbing.ReadTree(c.Tree)
-	if err != nil {
-		if !recover {
+// Reset moves HEAD (and the branch it points to, if any) to the commit,
+// resetting the index and working tree as well if mode requires so. Previous
+// position is kept in the reflog, so reset can be undone by resetting to HEAD@{1}
+func (c Commit) Reset(mode ResetMode) error {
+	switch mode {
+	case ResetMixed:
+		err := index.Reset(c.Tree)
+		if err != nil {
 			return err
 		}
-		finalError.origError = err
-		recoverErr := head.Checkout(false)
-		if recoverErr != nil {
-			finalError.recoverError = recoverErr
+	case ResetHard:
+		err := UpdateWorkingTree(c.Tree, true)
+		if err != nil {
+			return err
 		}
-		return finalError
 	}
-	return SetHead(c.OID)
+	return SetHead(c.OID, fmt.Sprintf("reset: moving to %s", c.OID))
 }
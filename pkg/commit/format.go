@@ -0,0 +1,120 @@
+package commit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i-hate-nicknames/gitik/pkg/identity"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// DateFormat is the layout dates are shown with by default
+const DateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+// Format commit using a template, where placeholders are replaced with
+// commit fields, mostly the same way git does:
+//
+//	%H, %h: commit id, abbreviated commit id
+//	%T, %t: tree id, abbreviated tree id
+//	%P, %p: parent id, abbreviated parent id
+//	%an, %ae, %ad, %at: author name, email, date and unix timestamp
+//	%cn, %ce, %cd, %ct: committer name, email, date and unix timestamp
+//	%s, %b, %B: subject, body and raw message
+//	%(trailers): trailers of the message, one per line
+//	%n, %%: newline and percent sign
+//
+// Unknown placeholders are left as is
+func (c Commit) Format(template string) string {
+	var out strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 == len(template) {
+			out.WriteByte(template[i])
+			continue
+		}
+		value, length, ok := c.placeholder(template[i+1:])
+		if !ok {
+			out.WriteByte(template[i])
+			continue
+		}
+		out.WriteString(value)
+		i += length
+	}
+	return out.String()
+}
+
+// placeholder returns value of the placeholder at the start of spec,
+// which follows the percent sign, and the length of the placeholder
+func (c Commit) placeholder(spec string) (string, int, bool) {
+	switch spec[0] {
+	case 'H':
+		return c.OID.String(), 1, true
+	case 'h':
+		return shortOID(c.OID), 1, true
+	case 'T':
+		return c.Tree.String(), 1, true
+	case 't':
+		return shortOID(c.Tree), 1, true
+	case 'P':
+		return optionalOID(c.Parent, c.Parent.String()), 1, true
+	case 'p':
+		return optionalOID(c.Parent, shortOID(c.Parent)), 1, true
+	case 's':
+		return c.Subject(), 1, true
+	case 'b':
+		return c.Body(), 1, true
+	case 'B':
+		return c.Message, 1, true
+	case 'n':
+		return "\n", 1, true
+	case '%':
+		return "%", 1, true
+	case 'a':
+		return signaturePlaceholder(c.Author, spec[1:])
+	case 'c':
+		return signaturePlaceholder(c.Committer, spec[1:])
+	case '(':
+		if strings.HasPrefix(spec, trailersPlaceholder) {
+			var lines []string
+			for _, t := range c.Trailers() {
+				lines = append(lines, t.Key+": "+t.Value)
+			}
+			return strings.Join(lines, "\n"), len(trailersPlaceholder), true
+		}
+	}
+	return "", 0, false
+}
+
+const trailersPlaceholder = "(trailers)"
+
+func signaturePlaceholder(sig identity.Signature, spec string) (string, int, bool) {
+	if len(spec) == 0 {
+		return "", 0, false
+	}
+	switch spec[0] {
+	case 'n':
+		return sig.Name, 2, true
+	case 'e':
+		return sig.Email, 2, true
+	case 'd':
+		return FormatDate(sig.When), 2, true
+	case 't':
+		return strconv.FormatInt(sig.When.Unix(), 10), 2, true
+	}
+	return "", 0, false
+}
+
+// FormatDate formats date with DateFormat, unknown dates are shown as empty
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(DateFormat)
+}
+
+func optionalOID(oid storage.OID, formatted string) string {
+	if oid == storage.ZeroOID {
+		return ""
+	}
+	return formatted
+}
@@ -0,0 +1,280 @@
+package commit
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// GraphName is filename of the commit-graph in the git directory. The graph
+// holds tree, parent, commit time and generation number of every commit
+// reachable from references at the time it was written, so that history can
+// be traversed without reading and decoding commit objects.
+// Generation number is the number of commits in the commit's history,
+// counting the commit itself, so the root commit has generation 1.
+//
+// The file starts with a header: magic "CGPH", format version and
+// the number of commits, followed by a record per commit, ordered by
+// commit id, and SHA-1 checksum of everything before it. Every record holds:
+//   - commit id, 20 bytes
+//   - tree id, 20 bytes
+//   - index of the parent record, 4 bytes, noParent for root commits
+//   - generation number, 4 bytes
+//   - commit time in seconds since epoch, 8 bytes
+//
+// All numbers are big-endian. Commits made after the graph was written are
+// not in it, and are read from the object database. Graph that is corrupt or
+// has unknown version is ignored
+const GraphName = "commit-graph"
+
+const (
+	graphMagic      = "CGPH"
+	graphVersion    = 1
+	graphHeaderSize = 12
+	graphRecordSize = 2*sha1.Size + 16
+	noParent        = 0xffffffff
+)
+
+// graph is the loaded commit-graph, nil if there is none
+var graph *commitGraph
+
+var graphLoaded bool
+
+type commitGraph struct {
+	records []byte
+	count   int
+}
+
+// graphEntry is what the commit-graph knows about a commit
+type graphEntry struct {
+	Tree       storage.OID
+	Parent     storage.OID
+	Generation uint32
+	When       int64
+}
+
+// WriteGraph writes commit-graph with all commits reachable from HEAD and
+// the references, replacing the existing one. Return number of commits in it
+func WriteGraph() (int, error) {
+	tips := []string{refs.Head}
+	names, err := refs.List()
+	if err != nil {
+		return 0, err
+	}
+	tips = append(tips, names...)
+	entries := make(map[storage.OID]graphEntry)
+	for _, name := range tips {
+		oid, err := refs.Read(name)
+		if errors.Is(err, refs.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if obj, err := storage.GetObject(oid); err != nil || obj.ObjType != storage.TypeCommit {
+			// references may point to other objects, like trees
+			continue
+		}
+		err = collectHistory(oid, entries)
+		if err != nil {
+			return 0, err
+		}
+	}
+	oids := make([]storage.OID, 0, len(entries))
+	for oid := range entries {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool {
+		return bytes.Compare(oids[i][:], oids[j][:]) < 0
+	})
+	index := make(map[storage.OID]uint32, len(oids))
+	for i, oid := range oids {
+		index[oid] = uint32(i)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(graphMagic)
+	header := make([]byte, graphHeaderSize-len(graphMagic))
+	header[0] = graphVersion
+	binary.BigEndian.PutUint32(header[4:], uint32(len(oids)))
+	buf.Write(header)
+	record := make([]byte, 16)
+	for _, oid := range oids {
+		entry := entries[oid]
+		parent := uint32(noParent)
+		if entry.Parent != storage.ZeroOID {
+			parent = index[entry.Parent]
+		}
+		buf.Write(oid[:])
+		buf.Write(entry.Tree[:])
+		binary.BigEndian.PutUint32(record[0:], parent)
+		binary.BigEndian.PutUint32(record[4:], entry.Generation)
+		binary.BigEndian.PutUint64(record[8:], uint64(entry.When))
+		buf.Write(record)
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	// write the new graph aside and move it in place, so that readers never
+	// see a partially written one
+	path := graphPath()
+	err = storage.WriteFile(path+".lock", buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	err = os.Rename(path+".lock", path)
+	if err != nil {
+		return 0, err
+	}
+	graph, graphLoaded = nil, false
+	return len(oids), nil
+}
+
+// collectHistory adds commit and all of its ancestors to the entries
+func collectHistory(oid storage.OID, entries map[storage.OID]graphEntry) error {
+	var chain []Commit
+	for oid != storage.ZeroOID {
+		if _, ok := entries[oid]; ok {
+			break
+		}
+		c, err := GetCommit(oid)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, c)
+		oid = c.Parent
+	}
+	// generation of a commit is known once its parent's is
+	for i := len(chain) - 1; i >= 0; i-- {
+		c := chain[i]
+		entry := graphEntry{Tree: c.Tree, Parent: c.Parent, Generation: 1, When: c.Committer.When.Unix()}
+		if c.Committer.When.IsZero() {
+			entry.When = 0
+		}
+		if c.Parent != storage.ZeroOID {
+			entry.Generation = entries[c.Parent].Generation + 1
+		}
+		entries[c.OID] = entry
+	}
+	return nil
+}
+
+// loadGraph returns the commit-graph, or nil if there is no usable one
+func loadGraph() *commitGraph {
+	if graphLoaded {
+		return graph
+	}
+	graphLoaded = true
+	data, err := ioutil.ReadFile(graphPath())
+	if err != nil || len(data) < graphHeaderSize+sha1.Size {
+		return nil
+	}
+	body, sum := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if string(body[:len(graphMagic)]) != graphMagic || body[len(graphMagic)] != graphVersion {
+		return nil
+	}
+	if expected := sha1.Sum(body); !bytes.Equal(expected[:], sum) {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint32(body[len(graphMagic)+4:]))
+	records := body[graphHeaderSize:]
+	if len(records) != count*graphRecordSize {
+		return nil
+	}
+	graph = &commitGraph{records: records, count: count}
+	return graph
+}
+
+// lookup finds the commit in the graph by binary search
+func (g *commitGraph) lookup(oid storage.OID) (graphEntry, bool) {
+	i := sort.Search(g.count, func(i int) bool {
+		return bytes.Compare(g.record(i)[:sha1.Size], oid[:]) >= 0
+	})
+	if i == g.count || !bytes.Equal(g.record(i)[:sha1.Size], oid[:]) {
+		return graphEntry{}, false
+	}
+	return g.entry(i), true
+}
+
+func (g *commitGraph) entry(i int) graphEntry {
+	record := g.record(i)
+	var entry graphEntry
+	copy(entry.Tree[:], record[sha1.Size:])
+	numbers := record[2*sha1.Size:]
+	if parent := binary.BigEndian.Uint32(numbers[0:]); parent != noParent && int(parent) < g.count {
+		copy(entry.Parent[:], g.record(int(parent))[:sha1.Size])
+	}
+	entry.Generation = binary.BigEndian.Uint32(numbers[4:])
+	entry.When = int64(binary.BigEndian.Uint64(numbers[8:]))
+	return entry
+}
+
+func (g *commitGraph) record(i int) []byte {
+	return g.records[i*graphRecordSize : (i+1)*graphRecordSize]
+}
+
+// parentOf returns parent of the commit, using the commit-graph if possible
+func parentOf(oid storage.OID) (storage.OID, error) {
+	if g := loadGraph(); g != nil {
+		if entry, ok := g.lookup(oid); ok {
+			return entry.Parent, nil
+		}
+	}
+	c, err := GetCommit(oid)
+	return c.Parent, err
+}
+
+// generation returns generation number of the commit
+func generation(oid storage.OID) (uint32, error) {
+	return generations{}.of(oid)
+}
+
+// generations memoizes generation numbers of the commits missing
+// from the commit-graph
+type generations map[storage.OID]uint32
+
+// of returns generation number of the commit. Commits missing from the
+// commit-graph are walked until one of their ancestors is found in it
+func (gens generations) of(oid storage.OID) (uint32, error) {
+	g := loadGraph()
+	start := oid
+	var chain []storage.OID
+	var known uint32
+	for oid != storage.ZeroOID {
+		if gen, ok := gens[oid]; ok {
+			known = gen
+			break
+		}
+		if g != nil {
+			if entry, ok := g.lookup(oid); ok {
+				known = entry.Generation
+				break
+			}
+		}
+		c, err := GetCommit(oid)
+		if err != nil {
+			return 0, err
+		}
+		chain = append(chain, oid)
+		oid = c.Parent
+	}
+	if len(chain) == 0 {
+		return known, nil
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		known++
+		gens[chain[i]] = known
+	}
+	return gens[start], nil
+}
+
+func graphPath() string {
+	return filepath.Join(constants.GitDir, GraphName)
+}
@@ -0,0 +1,119 @@
+package commit
+
+import (
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// LogRange returns commits that are reachable from any of the include
+// commits, but not from any of the exclude commits, newest first
+func LogRange(include, exclude []storage.OID) ([]Commit, error) {
+	excluded := make(map[storage.OID]bool)
+	for _, oid := range exclude {
+		// excluded commits are not shown, their ids are enough
+		for oid != storage.ZeroOID && !excluded[oid] {
+			excluded[oid] = true
+			var err error
+			oid, err = parentOf(oid)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	var result []Commit
+	for _, oid := range include {
+		for oid != storage.ZeroOID && !excluded[oid] {
+			c, err := GetCommit(oid)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, c)
+			// histories of several commits share ancestors,
+			// every commit should be listed once
+			excluded[oid] = true
+			oid = c.Parent
+		}
+	}
+	if len(include) > 1 {
+		// commits made in the same second are ordered by generation,
+		// so that children are still listed before their parents
+		gens := make(generations)
+		ordered := make([]uint32, len(result))
+		for i, c := range result {
+			gen, err := gens.of(c.OID)
+			if err != nil {
+				return nil, err
+			}
+			ordered[i] = gen
+		}
+		sort.Sort(byDate{result, ordered})
+	}
+	return result, nil
+}
+
+// byDate sorts commits newest first, using their generations to break ties
+type byDate struct {
+	commits     []Commit
+	generations []uint32
+}
+
+func (b byDate) Len() int { return len(b.commits) }
+
+func (b byDate) Less(i, j int) bool {
+	ti, tj := b.commits[i].Committer.When, b.commits[j].Committer.When
+	if ti.Unix() != tj.Unix() {
+		return ti.After(tj)
+	}
+	return b.generations[i] > b.generations[j]
+}
+
+func (b byDate) Swap(i, j int) {
+	b.commits[i], b.commits[j] = b.commits[j], b.commits[i]
+	b.generations[i], b.generations[j] = b.generations[j], b.generations[i]
+}
+
+// MergeBase returns the most recent common ancestor of the two commits,
+// or zero OID if their histories are unrelated
+func MergeBase(a, b storage.OID) (storage.OID, error) {
+	genA, err := generation(a)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	genB, err := generation(b)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	// commits have a single parent, so the common ancestor is at the same
+	// distance from the root in both histories
+	for ; genA > genB; genA-- {
+		a, err = parentOf(a)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	for ; genB > genA; genB-- {
+		b, err = parentOf(b)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	for a != b {
+		a, err = parentOf(a)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		b, err = parentOf(b)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	return a, nil
+}
+
+// IsAncestor reports whether commit a is in the history of commit b,
+// a commit is considered its own ancestor
+func IsAncestor(a, b storage.OID) (bool, error) {
+	base, err := MergeBase(a, b)
+	return base == a, err
+}
@@ -0,0 +1,45 @@
+package commit
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/editor"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// ErrEmptyMessage is returned when user left commit message empty
+var ErrEmptyMessage = errors.New("empty commit message")
+
+const messageHelp = "# Please enter the commit message for your changes. Lines starting\n" +
+	"# with '#' will be ignored, and an empty message aborts the commit.\n"
+
+// EditMessage lets user edit commit message in the editor, starting with
+// given message. Summary is shown to the user as a comment after the message,
+// it should consist of lines starting with the comment prefix
+func EditMessage(message, summary string) (string, error) {
+	path := filepath.Join(constants.GitDir, constants.CommitEditMsgName)
+	text := message + "\n\n" + messageHelp
+	if summary != "" {
+		text += "#\n" + summary
+	}
+	err := storage.WriteFile(path, []byte(text))
+	if err != nil {
+		return "", err
+	}
+	err = editor.Edit(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message = editor.StripComments(string(data))
+	if message == "" {
+		return "", ErrEmptyMessage
+	}
+	return message, nil
+}
@@ -0,0 +1,180 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
+	"github.com/i-hate-nicknames/gitik/pkg/merge"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// ConflictError is returned when changes cannot be applied to the working
+// tree cleanly. Conflicting files are left in the working tree with
+// conflict markers, so that they can be resolved by hand and committed
+type ConflictError struct {
+	Paths []string
+}
+
+func (ce ConflictError) Error() string {
+	return fmt.Sprintf("conflicts in %s", strings.Join(ce.Paths, ", "))
+}
+
+// PendingMessage returns commit message saved by an operation that stopped
+// because of conflicts, or empty string if there is no such operation
+func PendingMessage() (string, error) {
+	data, err := ioutil.ReadFile(pendingMessagePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+func savePendingMessage(message string) error {
+	return storage.WriteFile(pendingMessagePath(), []byte(message))
+}
+
+// pendingPick returns id of the commit being cherry-picked, if the
+// cherry-pick stopped because of conflicts, zero OID otherwise
+func pendingPick() (storage.OID, error) {
+	data, err := ioutil.ReadFile(pendingPickPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return storage.ZeroOID, nil
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return storage.MakeOID(data)
+}
+
+func savePendingPick(oid storage.OID) error {
+	return storage.WriteFile(pendingPickPath(), []byte(oid.String()))
+}
+
+// pendingTree returns id of the tree written to the working tree by
+// the operation that stopped because of conflicts, zero OID if there is none.
+// Its files are tracked until the operation is concluded, even if they are
+// not in HEAD, so that hard reset removes them
+func pendingTree() (storage.OID, error) {
+	data, err := ioutil.ReadFile(pendingTreePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return storage.ZeroOID, nil
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return storage.MakeOID(data)
+}
+
+func savePendingTree(oid storage.OID) error {
+	return storage.WriteFile(pendingTreePath(), []byte(oid.String()))
+}
+
+// savePendingConflicts remembers the conflicting paths together with
+// the tree they were written to the working tree in
+func savePendingConflicts(tree storage.OID, paths []string) error {
+	err := savePendingTree(tree)
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(pendingConflictsPath(), []byte(strings.Join(paths, "\n")))
+}
+
+// UnresolvedConflicts returns paths that conflicted in the operation that
+// stopped because of conflicts, and are not resolved in the current tree,
+// the index or the working tree if there is no index. A path is unresolved
+// if it's unchanged since the merge or still has conflict markers
+func UnresolvedConflicts() ([]string, error) {
+	data, err := ioutil.ReadFile(pendingConflictsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tree, err := pendingTree()
+	if err != nil {
+		return nil, err
+	}
+	merged, err := plumbing.ReadTreeFiles(tree)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		idx = nil
+	} else if err != nil {
+		return nil, err
+	}
+	var unresolved []string
+	for _, path := range strings.Split(string(data), "\n") {
+		if path == "" {
+			continue
+		}
+		current, ok, err := currentFile(idx, path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// removed, which resolves the conflict
+			continue
+		}
+		oid := storage.HashObject(current, storage.TypeBlob)
+		if oid == merged[path] || merge.HasConflictMarkers(current) {
+			unresolved = append(unresolved, path)
+		}
+	}
+	return unresolved, nil
+}
+
+// currentFile returns contents of the file as it would be committed,
+// from the index, or from the working tree if idx is nil
+func currentFile(idx *index.Index, path string) ([]byte, bool, error) {
+	if idx == nil {
+		data, err := ioutil.ReadFile(filepath.FromSlash(path))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return data, err == nil, err
+	}
+	entry, ok := idx.Get(path)
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := plumbing.ReadBlob(entry.OID)
+	return data, err == nil, err
+}
+
+// ClearPending forgets about operation that stopped because of conflicts
+func ClearPending() error {
+	paths := []string{pendingMessagePath(), pendingPickPath(), pendingTreePath(), pendingConflictsPath()}
+	for _, path := range paths {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func pendingMessagePath() string {
+	return filepath.Join(constants.GitDir, constants.MergeMsgName)
+}
+
+func pendingPickPath() string {
+	return filepath.Join(constants.GitDir, constants.CherryPickHeadName)
+}
+
+func pendingTreePath() string {
+	return filepath.Join(constants.GitDir, constants.MergeTreeName)
+}
+
+func pendingConflictsPath() string {
+	return filepath.Join(constants.GitDir, constants.MergeConflictsName)
+}
@@ -0,0 +1,91 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/merge"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// CherryPick applies changes introduced by the commit c, relative to its
+// parent, on top of HEAD and creates a new commit with the same message and
+// author. Return new commit's storage ID. If the changes conflict with HEAD,
+// ConflictError is returned, and the commit is remembered, so that the
+// following commit concludes the cherry-pick. If HEAD already has the
+// changes, EmptyCommitError is returned and no commit is made
+func CherryPick(c Commit) (storage.OID, error) {
+	head, err := GetHead()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	tree, err := ApplyChanges(c)
+	var conflict ConflictError
+	if errors.As(err, &conflict) {
+		err = savePendingMessage(c.Message)
+		if err == nil {
+			err = savePendingPick(c.OID)
+		}
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		return storage.ZeroOID, conflict
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	picked := Commit{Tree: tree, Parent: head.OID, Author: c.Author, Message: c.Message}
+	err = picked.checkEmpty()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return picked.Save("cherry-pick")
+}
+
+// ApplyChanges applies changes introduced by the commit c, relative to its
+// parent, to the tree of HEAD and writes the result to the working tree and
+// the index, without creating a commit. Return id of the resulting tree.
+// If the changes conflict with HEAD, ConflictError is returned. Local changes
+// are kept, unless they would be overwritten, then DirtyTreeError or
+// UntrackedFilesError is returned and nothing is changed
+func ApplyChanges(c Commit) (storage.OID, error) {
+	head, err := GetHead()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	parentTree, err := c.parentTree()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	theirsLabel := fmt.Sprintf("%s (%s)", shortOID(c.OID), c.Subject())
+	result, err := merge.Trees(parentTree, head.Tree, c.Tree, constants.HeadName, theirsLabel)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	err = UpdateWorkingTree(result.Tree, false)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if !result.Clean() {
+		err = savePendingConflicts(result.Tree, result.Conflicts)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		return storage.ZeroOID, ConflictError{Paths: result.Conflicts}
+	}
+	return result.Tree, nil
+}
+
+// parentTree returns tree of the commit's parent, or zero OID
+// standing for an empty tree if the commit has no parent
+func (c Commit) parentTree() (storage.OID, error) {
+	if c.Parent == storage.ZeroOID {
+		return storage.ZeroOID, nil
+	}
+	parent, err := GetCommit(c.Parent)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return parent.Tree, nil
+}
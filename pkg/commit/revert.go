@@ -0,0 +1,53 @@
+package commit
+
+import (
+	"fmt"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/merge"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Revert creates a new commit on top of HEAD that undoes changes introduced
+// by the commit c, relative to its parent. Return new commit's storage ID.
+// If reverted changes conflict with the later ones, ConflictError is returned,
+// and the message of the revert commit is saved for the following commit.
+// If the changes are already undone, EmptyCommitError is returned.
+// Local changes are kept, unless they would be overwritten, then
+// DirtyTreeError or UntrackedFilesError is returned and nothing is changed
+func Revert(c Commit) (storage.OID, error) {
+	head, err := GetHead()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	parentTree, err := c.parentTree()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	theirsLabel := fmt.Sprintf("parent of %s (%s)", shortOID(c.OID), c.Subject())
+	result, err := merge.Trees(c.Tree, head.Tree, parentTree, constants.HeadName, theirsLabel)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", c.Subject(), c.OID)
+	err = UpdateWorkingTree(result.Tree, false)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if !result.Clean() {
+		err = savePendingMessage(message)
+		if err == nil {
+			err = savePendingConflicts(result.Tree, result.Conflicts)
+		}
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		return storage.ZeroOID, ConflictError{Paths: result.Conflicts}
+	}
+	revert := Commit{Tree: result.Tree, Parent: head.OID, Message: message}
+	err = revert.checkEmpty()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return revert.Save("revert")
+}
@@ -0,0 +1,58 @@
+package commit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Trailer is a "Key: value" line at the end of the commit message,
+// like Signed-off-by or Co-authored-by
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// Trailers returns trailers of the commit message. Trailers are the last
+// paragraph of the message, other than the subject, if every line of it is
+// a trailer or a continuation of one, starting with whitespace
+func (c Commit) Trailers() []Trailer {
+	paragraphs := strings.Split(strings.TrimSpace(c.Message), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	var trailers []Trailer
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') && len(trailers) > 0 {
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 || !isTrailerKey(line[:colon]) {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: line[:colon], Value: strings.TrimSpace(line[colon+1:])})
+	}
+	return trailers
+}
+
+// TrailerValues returns values of the trailers with the key,
+// compared case-insensitively, e.g. all Signed-off-by values
+func (c Commit) TrailerValues(key string) []string {
+	var values []string
+	for _, t := range c.Trailers() {
+		if strings.EqualFold(t.Key, key) {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// isTrailerKey reports whether s is made of letters, digits and dashes
+func isTrailerKey(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
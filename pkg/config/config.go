@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// ConfigName is the filename of the repository configuration in the git
+// directory. It uses the same format as git config files:
+//
+//	[section]
+//		key = value
+//
+// Section and key names are case-insensitive, and are referred to
+// as section.key, e.g. signing.key
+const ConfigName = "config"
+
+// ErrNotSet is returned when configuration has no value for the key
+var ErrNotSet = errors.New("config value is not set")
+
+// Get returns value of the key, e.g. "commit.template"
+func Get(key string) (string, error) {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	lines, err := readLines()
+	if err != nil {
+		return "", err
+	}
+	value, found := "", false
+	current := ""
+	for _, line := range lines {
+		if s, ok := parseSection(line); ok {
+			current = s
+			continue
+		}
+		k, v, ok := parseEntry(line)
+		// the last value wins, like in git
+		if ok && current == section && k == name {
+			value, found = v, true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%s: %w", key, ErrNotSet)
+	}
+	return value, nil
+}
+
+// GetDefault returns value of the key, or def if it's not set
+func GetDefault(key, def string) (string, error) {
+	value, err := Get(key)
+	if errors.Is(err, ErrNotSet) {
+		return def, nil
+	}
+	return value, err
+}
+
+// Set sets value of the key, replacing the previous value if any
+func Set(key, value string) error {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	lines, err := readLines()
+	if err != nil {
+		return err
+	}
+	entry := fmt.Sprintf("\t%s = %s", name, value)
+	current, sectionEnd := "", -1
+	for i, line := range lines {
+		if s, ok := parseSection(line); ok {
+			current = s
+			continue
+		}
+		if current != section {
+			continue
+		}
+		sectionEnd = i + 1
+		if k, _, ok := parseEntry(line); ok && k == name {
+			lines[i] = entry
+			return writeLines(lines)
+		}
+	}
+	if sectionEnd == -1 {
+		lines = append(lines, fmt.Sprintf("[%s]", section), entry)
+	} else {
+		lines = append(lines[:sectionEnd], append([]string{entry}, lines[sectionEnd:]...)...)
+	}
+	return writeLines(lines)
+}
+
+// Path returns value of the key that holds a filesystem path. Relative
+// paths are relative to the root of the repository, ~ stands for the home
+// directory. If the key is not set, def is returned
+func Path(key, def string) (string, error) {
+	value, err := GetDefault(key, def)
+	if err != nil || !strings.HasPrefix(value, "~/") {
+		return value, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, value[2:]), nil
+}
+
+func splitKey(key string) (string, string, error) {
+	dot := strings.LastIndex(key, ".")
+	if dot <= 0 || dot == len(key)-1 {
+		return "", "", fmt.Errorf("invalid config key %q, expected section.key", key)
+	}
+	return strings.ToLower(key[:dot]), strings.ToLower(key[dot+1:]), nil
+}
+
+// parseSection parses section header, like [section] or [section "sub"],
+// the latter is referred to as section.sub
+func parseSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	fields := strings.Fields(line[1 : len(line)-1])
+	for i := range fields {
+		fields[i] = strings.Trim(fields[i], `"`)
+	}
+	return strings.ToLower(strings.Join(fields, ".")), true
+}
+
+func parseEntry(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return "", "", false
+	}
+	parts := strings.SplitN(line, "=", 2)
+	key := strings.ToLower(strings.TrimSpace(parts[0]))
+	if len(parts) == 1 {
+		// a key without value is a boolean flag
+		return key, "true", true
+	}
+	return key, strings.Trim(strings.TrimSpace(parts[1]), `"`), true
+}
+
+func readLines() ([]string, error) {
+	data, err := ioutil.ReadFile(configPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func writeLines(lines []string) error {
+	return storage.WriteFile(configPath(), []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+func configPath() string {
+	return filepath.Join(constants.GitDir, ConfigName)
+}
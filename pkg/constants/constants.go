@@ -6,3 +6,22 @@ const GitDir = ".gitik"
 
 // HeadName is filename that should contain object id of the most recent commit
 const HeadName = "HEAD"
+
+// MergeMsgName is filename that holds message for the commit that will conclude
+// an operation stopped because of conflicts, such as revert
+const MergeMsgName = "MERGE_MSG"
+
+// CherryPickHeadName is filename that holds object id of the commit being
+// cherry-picked, when cherry-pick stopped because of conflicts
+const CherryPickHeadName = "CHERRY_PICK_HEAD"
+
+// MergeTreeName is filename that holds object id of the tree written to the
+// working tree by an operation stopped because of conflicts
+const MergeTreeName = "MERGE_TREE"
+
+// MergeConflictsName is filename that lists paths that conflicted in
+// an operation stopped because of conflicts, one per line
+const MergeConflictsName = "MERGE_CONFLICTS"
+
+// CommitEditMsgName is filename of the commit message being edited by the user
+const CommitEditMsgName = "COMMIT_EDITMSG"
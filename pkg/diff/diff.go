@@ -0,0 +1,107 @@
+package diff
+
+import "strings"
+
+// Hunk is a single change between two versions of a sequence of lines:
+// lines Old[OldStart:OldEnd] are replaced with lines New[NewStart:NewEnd].
+// Empty old range means pure insertion, empty new range means pure deletion
+type Hunk struct {
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+// Lines splits data into lines, keeping line terminators, so that
+// joining the lines gives back the original data
+func Lines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Diff returns the shortest list of hunks that turns old lines into new,
+// ordered by position. Lines not covered by any hunk are unchanged
+func Diff(old, new []string) []Hunk {
+	var hunks []Hunk
+	i, j := 0, 0
+	matches := append(match(old, new), [2]int{len(old), len(new)})
+	for _, m := range matches {
+		if m[0] > i || m[1] > j {
+			hunks = append(hunks, Hunk{OldStart: i, OldEnd: m[0], NewStart: j, NewEnd: m[1]})
+		}
+		i, j = m[0]+1, m[1]+1
+	}
+	return hunks
+}
+
+// match finds longest common subsequence of a and b using Myers' algorithm,
+// and returns pairs of indices of equal lines in a and b, in ascending order
+func match(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds furthest reaching x for every diagonal k in [-d, d]
+	// after d edits, which is enough to restore the path backwards
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+		if v[offset+n-m] >= n && n-m >= -d && n-m <= d {
+			break
+		}
+	}
+	return backtrack(trace, n, m)
+}
+
+func backtrack(trace [][]int, n, m int) [][2]int {
+	var matches [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches = append(matches, [2]int{x, y})
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
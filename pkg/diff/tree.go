@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"io"
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Change is a file that differs between two trees. Zero Old means
+// the file was added, zero New means the file was deleted
+type Change struct {
+	Path string
+	Old  storage.OID
+	New  storage.OID
+}
+
+// Status returns a letter describing the change, the same way git does:
+// A for added, D for deleted and M for modified files
+func (c Change) Status() string {
+	switch {
+	case c.Old == storage.ZeroOID:
+		return "A"
+	case c.New == storage.ZeroOID:
+		return "D"
+	default:
+		return "M"
+	}
+}
+
+// Trees returns files that differ between old and new trees, ordered by path.
+// Zero OID can be used for any of the trees to denote an empty tree
+func Trees(old, new storage.OID) ([]Change, error) {
+	oldFiles, err := plumbing.ReadTreeFiles(old)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := plumbing.ReadTreeFiles(new)
+	if err != nil {
+		return nil, err
+	}
+	return Files(oldFiles, newFiles), nil
+}
+
+// Files returns files that differ between two sets of files,
+// keyed by path, ordered by path
+func Files(old, new map[string]storage.OID) []Change {
+	var changes []Change
+	for path, oldOID := range old {
+		if newOID := new[path]; newOID != oldOID {
+			changes = append(changes, Change{Path: path, Old: oldOID, New: newOID})
+		}
+	}
+	for path, newOID := range new {
+		if _, ok := old[path]; !ok {
+			changes = append(changes, Change{Path: path, New: newOID})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// WritePatch writes changes in the unified diff format
+func WritePatch(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		oldName, newName := "a/"+c.Path, "b/"+c.Path
+		oldData, err := readBlob(c.Old)
+		if err != nil {
+			return err
+		}
+		newData, err := readBlob(c.New)
+		if err != nil {
+			return err
+		}
+		if c.Old == storage.ZeroOID {
+			oldName = "/dev/null"
+		}
+		if c.New == storage.ZeroOID {
+			newName = "/dev/null"
+		}
+		err = Unified(w, oldName, newName, oldData, newData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat returns the number of lines added and removed by the change
+func (c Change) Stat() (added, removed int, err error) {
+	oldData, err := readBlob(c.Old)
+	if err != nil {
+		return 0, 0, err
+	}
+	newData, err := readBlob(c.New)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, hunk := range Diff(Lines(oldData), Lines(newData)) {
+		added += hunk.NewEnd - hunk.NewStart
+		removed += hunk.OldEnd - hunk.OldStart
+	}
+	return added, removed, nil
+}
+
+// readBlob returns contents of the blob, zero OID stands for an empty file
+func readBlob(oid storage.OID) ([]byte, error) {
+	if oid == storage.ZeroOID {
+		return nil, nil
+	}
+	return plumbing.ReadBlob(oid)
+}
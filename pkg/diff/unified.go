@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines shown around every change
+const ContextLines = 3
+
+// Unified writes difference between old and new versions of a file
+// in the unified diff format, with names of the files in the header
+func Unified(w io.Writer, oldName, newName string, old, new []byte) error {
+	oldLines, newLines := Lines(old), Lines(new)
+	hunks := Diff(oldLines, newLines)
+	if len(hunks) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	if err != nil {
+		return err
+	}
+	for len(hunks) > 0 {
+		// hunks that are close to each other share context lines
+		// and are shown as a single one
+		n := 1
+		for n < len(hunks) && hunks[n].OldStart-hunks[n-1].OldEnd <= 2*ContextLines {
+			n++
+		}
+		err = writeGroup(w, oldLines, newLines, hunks[:n])
+		if err != nil {
+			return err
+		}
+		hunks = hunks[n:]
+	}
+	return nil
+}
+
+func writeGroup(w io.Writer, old, new []string, group []Hunk) error {
+	first, last := group[0], group[len(group)-1]
+	start := maxInt(0, first.OldStart-ContextLines)
+	end := minInt(len(old), last.OldEnd+ContextLines)
+	newStart := first.NewStart - (first.OldStart - start)
+	newEnd := last.NewEnd + (end - last.OldEnd)
+	_, err := fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(start, end), hunkRange(newStart, newEnd))
+	if err != nil {
+		return err
+	}
+	var buf strings.Builder
+	pos := start
+	for _, h := range group {
+		writePrefixed(&buf, " ", old[pos:h.OldStart])
+		writePrefixed(&buf, "-", old[h.OldStart:h.OldEnd])
+		writePrefixed(&buf, "+", new[h.NewStart:h.NewEnd])
+		pos = h.OldEnd
+	}
+	writePrefixed(&buf, " ", old[pos:end])
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
+
+// hunkRange formats range of lines as 1-based "start,count", count is
+// omitted for single lines, empty ranges refer to the line right before them
+func hunkRange(start, end int) string {
+	switch end - start {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, end-start)
+	}
+}
+
+func writePrefixed(buf *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(prefix + line)
+		if !strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
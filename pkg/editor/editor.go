@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// environment variables to take editor command from, in order of priority
+var editorEnvs = []string{"GITIK_EDITOR", "VISUAL", "EDITOR"}
+
+const defaultEditor = "vi"
+
+// Command returns the editor command line configured by the user
+func Command() string {
+	for _, env := range editorEnvs {
+		if editor := os.Getenv(env); editor != "" {
+			return editor
+		}
+	}
+	return defaultEditor
+}
+
+// Edit opens file under given path in the user's editor
+// and waits until the editor exits
+func Edit(path string) error {
+	editor := Command()
+	// run through shell, so that editor command may contain arguments
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// CommentPrefix starts lines of edited text that are hints for the user
+// and not part of the text itself
+const CommentPrefix = "#"
+
+// StripComments removes comment lines from the text, as well as
+// leading and trailing blank lines
+func StripComments(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, CommentPrefix) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
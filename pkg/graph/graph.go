@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Render writes commits, newest first, with the history drawn to the left
+// of them. Every line of history, a lane, is a column of "|" characters,
+// commits are drawn as "*" in their lane, and lanes of commits that share
+// a parent are joined with "/" right before the parent.
+// describe is called to get text shown next to the commit, which may
+// span several lines
+func Render(w io.Writer, commits []commit.Commit, describe func(commit.Commit) string) error {
+	// lanes hold the commit every line of history expects to see next
+	var lanes []storage.OID
+	for _, c := range commits {
+		col := -1
+		for i := len(lanes) - 1; i >= 0; i-- {
+			if lanes[i] != c.OID {
+				continue
+			}
+			if col != -1 {
+				// several children of this commit were shown, their
+				// lanes are joined into the leftmost one
+				_, err := fmt.Fprintln(w, joinLine(len(lanes), i, col))
+				if err != nil {
+					return err
+				}
+				lanes = append(lanes[:col], lanes[col+1:]...)
+			}
+			col = i
+		}
+		if col == -1 {
+			lanes = append(lanes, c.OID)
+			col = len(lanes) - 1
+		}
+		lines := strings.Split(describe(c), "\n")
+		_, err := fmt.Fprintf(w, "%s %s\n", laneLine(len(lanes), col, '*'), lines[0])
+		if err != nil {
+			return err
+		}
+		lanes[col] = c.Parent
+		padding := laneLine(len(lanes), col, '|')
+		if c.Parent == storage.ZeroOID {
+			padding = laneLine(len(lanes), col, ' ')
+		}
+		for _, line := range lines[1:] {
+			_, err := fmt.Fprintln(w, strings.TrimRight(padding+" "+line, " "))
+			if err != nil {
+				return err
+			}
+		}
+		if c.Parent == storage.ZeroOID {
+			// root commit ends its lane, lanes to the right of it move left
+			if col < len(lanes)-1 {
+				_, err := fmt.Fprintln(w, joinLine(len(lanes), -1, col))
+				if err != nil {
+					return err
+				}
+			}
+			lanes = append(lanes[:col], lanes[col+1:]...)
+		}
+	}
+	return nil
+}
+
+// laneLine draws n lanes, with lane col drawn as mark
+func laneLine(n, col int, mark byte) string {
+	line := []byte(strings.Repeat("| ", n))
+	line[2*col] = mark
+	return strings.TrimRight(string(line), " ")
+}
+
+// joinLine draws lane from moving into lane to, or just ending if to is
+// negative, out of n lanes. Lanes right of from move one column to the left
+func joinLine(n, to, from int) string {
+	line := []byte(strings.Repeat(" ", 2*n))
+	for i := 0; i < from; i++ {
+		line[2*i] = '|'
+	}
+	if to >= 0 {
+		for p := 2*to + 1; p < 2*from-1; p += 2 {
+			line[p] = '_'
+		}
+		line[2*from-1] = '/'
+	}
+	for i := from + 1; i < n; i++ {
+		line[2*i-1] = '/'
+	}
+	return strings.TrimRight(string(line), " ")
+}
@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+)
+
+// Dir is the directory in the git directory where hooks are kept.
+// A hook is an executable file named after the hook, e.g.
+// .gitik/hooks/pre-commit, missing or non-executable hooks are skipped.
+// Hooks run in the root of the repository, with GITIK_DIR environment
+// variable set to the absolute path of the git directory
+const Dir = "hooks"
+
+// Hooks run by gitik commands. Non-zero exit status of the hooks run before
+// an operation aborts it, the hooks run after it cannot undo the operation.
+// Commit hooks are run only by commit, commits made by revert, cherry-pick
+// and rebase run PreMerge instead
+const (
+	// PreCommit runs before commit, before the message is entered.
+	// Takes no arguments
+	PreCommit = "pre-commit"
+	// CommitMsg runs before commit, with the only argument being path to
+	// the file with the commit message. The hook may edit the message
+	CommitMsg = "commit-msg"
+	// PostCommit runs after commit is made. Takes no arguments,
+	// its exit status is ignored
+	PostCommit = "post-commit"
+	// PostCheckout runs after checkout, with id of the previous HEAD, id of
+	// the new HEAD, and a flag that is always 1, as only whole commits
+	// are checked out. Its exit status becomes the status of checkout
+	PostCheckout = "post-checkout"
+	// PreMerge runs before revert, cherry-pick, rebase and stash apply
+	// merge changes into the working tree, with the name of the command,
+	// "revert", "cherry-pick", "rebase" or "stash", as the only argument.
+	// It does not run when continuing an operation stopped by conflicts
+	PreMerge = "pre-merge"
+	// PrePush is reserved for push, which gitik does not have yet, so it
+	// never runs. It will run before anything is pushed, with the name
+	// and the location of the remote as arguments
+	PrePush = "pre-push"
+)
+
+// Error is returned when a hook exits with non-zero status
+type Error struct {
+	Hook string
+	Err  error
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s hook failed: %s", e.Hook, e.Err)
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the hook with given arguments, if it exists,
+// and waits until it finishes
+func Run(name string, args ...string) error {
+	path := filepath.Join(constants.GitDir, Dir, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return nil
+	}
+	gitDir, err := filepath.Abs(constants.GitDir)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("./"+path, args...)
+	cmd.Env = append(os.Environ(), "GITIK_DIR="+gitDir)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return Error{Hook: name, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity is a person that performs changes in the repository
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// environment variables that override identity detected from the system
+const (
+	nameEnv  = "GITIK_AUTHOR_NAME"
+	emailEnv = "GITIK_AUTHOR_EMAIL"
+)
+
+// Current returns identity of the user running gitik. Environment variables
+// GITIK_AUTHOR_NAME and GITIK_AUTHOR_EMAIL take priority, otherwise
+// the identity is guessed from the system user and host names
+func Current() Identity {
+	id := Identity{Name: os.Getenv(nameEnv), Email: os.Getenv(emailEnv)}
+	if id.Name != "" && id.Email != "" {
+		return id
+	}
+	login := "unknown"
+	if u, err := user.Current(); err == nil {
+		login = u.Username
+		if id.Name == "" && u.Name != "" {
+			id.Name = u.Name
+		}
+	}
+	if id.Name == "" {
+		id.Name = login
+	}
+	if id.Email == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
+		id.Email = login + "@" + host
+	}
+	return id
+}
+
+func (id Identity) String() string {
+	return fmt.Sprintf("%s <%s>", id.Name, id.Email)
+}
+
+// ErrInvalidIdentity is returned when identity cannot be parsed
+var ErrInvalidIdentity = errors.New("invalid identity")
+
+// Parse identity in the "Name <email>" form, String counterpart
+func Parse(s string) (Identity, error) {
+	open := strings.LastIndex(s, "<")
+	if open == -1 || !strings.HasSuffix(s, ">") {
+		return Identity{}, ErrInvalidIdentity
+	}
+	return Identity{
+		Name:  strings.TrimSpace(s[:open]),
+		Email: s[open+1 : len(s)-1],
+	}, nil
+}
+
+// Signature is an identity together with the moment of time an action was
+// performed, e.g. when a commit was made or a reference was moved
+type Signature struct {
+	Identity
+	When time.Time
+}
+
+// Now returns signature of the current user at the current time
+func Now() Signature {
+	return Signature{Identity: Current(), When: time.Now()}
+}
+
+// String encodes signature as "Name <email> unix-time zone", the same way git does
+func (s Signature) String() string {
+	return fmt.Sprintf("%s %d %s", s.Identity, s.When.Unix(), s.When.Format("-0700"))
+}
+
+// ParseSignature parses signature encoded by Signature.String
+func ParseSignature(s string) (Signature, error) {
+	parts := strings.Split(s, " ")
+	if len(parts) < 3 {
+		return Signature{}, ErrInvalidIdentity
+	}
+	id, err := Parse(strings.Join(parts[:len(parts)-2], " "))
+	if err != nil {
+		return Signature{}, err
+	}
+	unix, err := strconv.ParseInt(parts[len(parts)-2], 10, 64)
+	if err != nil {
+		return Signature{}, ErrInvalidIdentity
+	}
+	zone, err := time.Parse("-0700", parts[len(parts)-1])
+	if err != nil {
+		return Signature{}, ErrInvalidIdentity
+	}
+	when := time.Unix(unix, 0).In(zone.Location())
+	return Signature{Identity: id, When: when}, nil
+}
@@ -0,0 +1,354 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Name is filename of the index in the git directory. The index, also known
+// as the staging area, lists the files that will make up the next commit.
+// Repository without an index commits the whole working tree, the index is
+// created by the first add or rm, starting with the files of HEAD.
+//
+// The file starts with a header: magic "GIDX", format version and the number
+// of entries, followed by an entry per file, ordered by path, and SHA-1
+// checksum of everything before it. Every entry holds:
+//   - blob id, 20 bytes
+//   - file mode, 4 bytes
+//   - file size, 8 bytes
+//   - modification time, seconds and nanoseconds since epoch, 8 and 4 bytes
+//   - path length, 2 bytes, followed by the path relative to the repository root
+//
+// All numbers are big-endian. Size and modification time are those of the file
+// when it was added, they tell whether the file may have changed since then
+const Name = "index"
+
+const (
+	magic      = "GIDX"
+	version    = 1
+	headerSize = 12
+	// size of the entry without the path
+	entrySize = sha1.Size + 26
+	maxPath   = 0xffff
+)
+
+// ErrNoIndex is returned when reading index of the repository that has none
+var ErrNoIndex = errors.New("no index")
+
+// ErrNotInIndex is returned when removing a file that is not in the index
+var ErrNotInIndex = errors.New("did not match any files in the index")
+
+// Entry is a file in the index
+type Entry struct {
+	Path    string
+	OID     storage.OID
+	Mode    os.FileMode
+	Size    int64
+	ModTime time.Time
+}
+
+// Unchanged reports whether the file still has the size and modification
+// time it had when added, so it can be assumed to have the same contents.
+// Entries without stat info, such as the ones read from a tree, are never
+// considered unchanged
+func (e Entry) Unchanged(info os.FileInfo) bool {
+	return !e.ModTime.IsZero() && info.Size() == e.Size && info.ModTime().Equal(e.ModTime)
+}
+
+// Index is the staging area
+type Index struct {
+	entries map[string]Entry
+}
+
+// Exists reports whether repository has an index
+func Exists() bool {
+	_, err := os.Stat(path())
+	return err == nil
+}
+
+// Read reads the index of the repository, ErrNoIndex if there is none
+func Read() (*Index, error) {
+	data, err := ioutil.ReadFile(path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", Name, err)
+	}
+	return idx, nil
+}
+
+// ReadOrCreate reads the index of the repository, or starts a new one with
+// the files of the tree, if there is none
+func ReadOrCreate(tree storage.OID) (*Index, error) {
+	idx, err := Read()
+	if errors.Is(err, ErrNoIndex) {
+		return FromTree(tree)
+	}
+	return idx, err
+}
+
+// FromTree makes index with the files of the tree. Zero OID is an empty tree
+func FromTree(tree storage.OID) (*Index, error) {
+	files, err := plumbing.ReadTreeFiles(tree)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{entries: make(map[string]Entry, len(files))}
+	for name, oid := range files {
+		idx.entries[name] = Entry{Path: name, OID: oid, Mode: 0644}
+	}
+	return idx, nil
+}
+
+// Reset replaces contents of the index with the files of the tree. Nothing
+// is done if repository has no index. Stat info of the files that stay
+// the same is kept
+func Reset(tree storage.OID) error {
+	old, err := Read()
+	if errors.Is(err, ErrNoIndex) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	idx, err := FromTree(tree)
+	if err != nil {
+		return err
+	}
+	for name, entry := range idx.entries {
+		if previous, ok := old.entries[name]; ok && previous.OID == entry.OID {
+			idx.entries[name] = previous
+		}
+	}
+	return idx.Write()
+}
+
+// Get returns the entry of the file
+func (idx *Index) Get(name string) (Entry, bool) {
+	entry, ok := idx.entries[name]
+	return entry, ok
+}
+
+// Entries returns all entries ordered by path
+func (idx *Index) Entries() []Entry {
+	entries := make([]Entry, 0, len(idx.entries))
+	for _, entry := range idx.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Files returns blob ids of the files keyed by their path,
+// the same way plumbing.ReadTreeFiles does
+func (idx *Index) Files() map[string]storage.OID {
+	files := make(map[string]storage.OID, len(idx.entries))
+	for name, entry := range idx.entries {
+		files[name] = entry.OID
+	}
+	return files
+}
+
+// Add stores the file in the object database and puts it in the index,
+// replacing the previous entry. Directories are added with all of their
+// files, and the files under the path that are gone are removed from the index.
+// Return paths of the added and removed files
+func (idx *Index) Add(name string) (added, removed []string, err error) {
+	name, err = Clean(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if name != "." && plumbing.IsIgnored(name) {
+		return nil, nil, fmt.Errorf("%s: path is ignored", name)
+	}
+	for _, entry := range idx.under(name) {
+		if _, err := os.Lstat(entry.Path); errors.Is(err, os.ErrNotExist) {
+			delete(idx.entries, entry.Path)
+			removed = append(removed, entry.Path)
+		}
+	}
+	if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) && len(removed) > 0 {
+		return nil, removed, nil
+	}
+	err = filepath.Walk(name, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != name && plumbing.IsIgnored(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		entry, err := addFile(filepath.ToSlash(p), info)
+		if err != nil {
+			return err
+		}
+		if previous, ok := idx.entries[entry.Path]; !ok || previous.OID != entry.OID {
+			added = append(added, entry.Path)
+		}
+		idx.entries[entry.Path] = entry
+		return nil
+	})
+	return added, removed, err
+}
+
+func addFile(name string, info os.FileInfo) (Entry, error) {
+	if len(name) > maxPath {
+		return Entry{}, fmt.Errorf("%s: path is too long", name)
+	}
+	oid, err := plumbing.WriteFile(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Path: name, OID: oid, Mode: info.Mode().Perm(), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Remove removes the file from the index, or all the files
+// if path is a directory. Return the removed entries
+func (idx *Index) Remove(name string) ([]Entry, error) {
+	name, err := Clean(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.under(name)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotInIndex)
+	}
+	for _, entry := range entries {
+		delete(idx.entries, entry.Path)
+	}
+	return entries, nil
+}
+
+// under returns entries of the file, or of all the files in the directory
+func (idx *Index) under(name string) []Entry {
+	var entries []Entry
+	for _, entry := range idx.Entries() {
+		if name == "." || entry.Path == name || strings.HasPrefix(entry.Path, name+"/") {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// WriteTree writes the files of the index as a tree to the object
+// database. Return id of the tree
+func (idx *Index) WriteTree() (storage.OID, error) {
+	return plumbing.WriteTreeFiles(idx.Files())
+}
+
+// Write replaces the index of the repository with this one
+func (idx *Index) Write() error {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	header := make([]byte, headerSize-len(magic))
+	header[0] = version
+	binary.BigEndian.PutUint32(header[4:], uint32(len(idx.entries)))
+	buf.Write(header)
+	record := make([]byte, entrySize-sha1.Size)
+	for _, entry := range idx.Entries() {
+		buf.Write(entry.OID[:])
+		binary.BigEndian.PutUint32(record[0:], uint32(entry.Mode))
+		binary.BigEndian.PutUint64(record[4:], uint64(entry.Size))
+		var sec int64
+		var nsec int
+		if !entry.ModTime.IsZero() {
+			sec, nsec = entry.ModTime.Unix(), entry.ModTime.Nanosecond()
+		}
+		binary.BigEndian.PutUint64(record[12:], uint64(sec))
+		binary.BigEndian.PutUint32(record[20:], uint32(nsec))
+		binary.BigEndian.PutUint16(record[24:], uint16(len(entry.Path)))
+		buf.Write(record)
+		buf.WriteString(entry.Path)
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	// the same as with commit-graph, readers never see a partially written index
+	err := storage.WriteFile(path()+".lock", buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.Rename(path()+".lock", path())
+}
+
+func decode(data []byte) (*Index, error) {
+	if len(data) < headerSize+sha1.Size {
+		return nil, errors.New("file is too short")
+	}
+	body, sum := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if string(body[:len(magic)]) != magic {
+		return nil, errors.New("bad signature")
+	}
+	if body[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported version %d", body[len(magic)])
+	}
+	if expected := sha1.Sum(body); !bytes.Equal(expected[:], sum) {
+		return nil, errors.New("checksum mismatch")
+	}
+	count := int(binary.BigEndian.Uint32(body[len(magic)+4:]))
+	idx := &Index{entries: make(map[string]Entry, count)}
+	rest := body[headerSize:]
+	for i := 0; i < count; i++ {
+		if len(rest) < entrySize {
+			return nil, errors.New("truncated entry")
+		}
+		var entry Entry
+		copy(entry.OID[:], rest)
+		record := rest[sha1.Size:entrySize]
+		entry.Mode = os.FileMode(binary.BigEndian.Uint32(record[0:]))
+		entry.Size = int64(binary.BigEndian.Uint64(record[4:]))
+		sec := int64(binary.BigEndian.Uint64(record[12:]))
+		nsec := int64(binary.BigEndian.Uint32(record[20:]))
+		if sec != 0 || nsec != 0 {
+			entry.ModTime = time.Unix(sec, nsec)
+		}
+		length := int(binary.BigEndian.Uint16(record[24:]))
+		rest = rest[entrySize:]
+		if len(rest) < length {
+			return nil, errors.New("truncated entry")
+		}
+		entry.Path = string(rest[:length])
+		rest = rest[length:]
+		idx.entries[entry.Path] = entry
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after the entries")
+	}
+	return idx, nil
+}
+
+// Clean turns path given by the user into the form used in the index:
+// relative to the repository root, with forward slashes
+func Clean(name string) (string, error) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if name == ".." || strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+		return "", fmt.Errorf("%s: outside repository", name)
+	}
+	return name, nil
+}
+
+func path() string {
+	return filepath.Join(constants.GitDir, Name)
+}
@@ -0,0 +1,134 @@
+package merge
+
+import (
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+)
+
+// conflict markers surrounding both versions of a conflicting region
+const (
+	markerOurs   = "<<<<<<<"
+	markerSep    = "======="
+	markerTheirs = ">>>>>>>"
+)
+
+// HasConflictMarkers reports whether data has lines starting with
+// conflict markers, i.e. conflicts in it are not resolved yet
+func HasConflictMarkers(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, markerOurs) || strings.HasPrefix(line, markerTheirs) {
+			return true
+		}
+	}
+	return false
+}
+
+// Files merges changes made to base in ours and theirs versions of a file.
+// Changes to different regions of the file are combined, identical changes
+// are applied once. When both sides change the same region differently,
+// both versions are written to the result surrounded by conflict markers
+// labeled with oursLabel and theirsLabel, and clean is false
+func Files(base, ours, theirs []byte, oursLabel, theirsLabel string) (merged []byte, clean bool) {
+	baseLines := diff.Lines(base)
+	oursLines := diff.Lines(ours)
+	theirsLines := diff.Lines(theirs)
+	oursHunks := diff.Diff(baseLines, oursLines)
+	theirsHunks := diff.Diff(baseLines, theirsLines)
+
+	var out strings.Builder
+	clean = true
+	pos := 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// collect a group of hunks from both sides that overlap or touch each
+		// other, these have to be resolved together
+		var og, tg []diff.Hunk
+		var lo, hi int
+		if j == len(theirsHunks) || (i < len(oursHunks) && oursHunks[i].OldStart <= theirsHunks[j].OldStart) {
+			lo, hi = oursHunks[i].OldStart, oursHunks[i].OldEnd
+		} else {
+			lo, hi = theirsHunks[j].OldStart, theirsHunks[j].OldEnd
+		}
+		for grown := true; grown; {
+			grown = false
+			for ; i < len(oursHunks) && oursHunks[i].OldStart <= hi; i++ {
+				og = append(og, oursHunks[i])
+				hi = maxInt(hi, oursHunks[i].OldEnd)
+				grown = true
+			}
+			for ; j < len(theirsHunks) && theirsHunks[j].OldStart <= hi; j++ {
+				tg = append(tg, theirsHunks[j])
+				hi = maxInt(hi, theirsHunks[j].OldEnd)
+				grown = true
+			}
+		}
+		writeLines(&out, baseLines[pos:lo])
+		oursRegion := apply(baseLines, oursLines, og, lo, hi)
+		theirsRegion := apply(baseLines, theirsLines, tg, lo, hi)
+		switch {
+		case len(tg) == 0:
+			writeLines(&out, oursRegion)
+		case len(og) == 0:
+			writeLines(&out, theirsRegion)
+		case equal(oursRegion, theirsRegion):
+			writeLines(&out, oursRegion)
+		default:
+			clean = false
+			out.WriteString(markerOurs + " " + oursLabel + "\n")
+			writeRegion(&out, oursRegion)
+			out.WriteString(markerSep + "\n")
+			writeRegion(&out, theirsRegion)
+			out.WriteString(markerTheirs + " " + theirsLabel + "\n")
+		}
+		pos = hi
+	}
+	writeLines(&out, baseLines[pos:])
+	return []byte(out.String()), clean
+}
+
+// apply hunks to base[lo:hi], returning the lines this region has in the changed version
+func apply(base, changed []string, hunks []diff.Hunk, lo, hi int) []string {
+	var result []string
+	pos := lo
+	for _, h := range hunks {
+		result = append(result, base[pos:h.OldStart]...)
+		result = append(result, changed[h.NewStart:h.NewEnd]...)
+		pos = h.OldEnd
+	}
+	return append(result, base[pos:hi]...)
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// writeRegion writes lines of a conflicting region, making sure the
+// following conflict marker starts on its own line
+func writeRegion(out *strings.Builder, lines []string) {
+	writeLines(out, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
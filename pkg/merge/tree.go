@@ -0,0 +1,105 @@
+package merge
+
+import (
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Result of a three-way merge of trees
+type Result struct {
+	// Tree is the merged tree. Files that could not be merged cleanly
+	// are stored with conflict markers
+	Tree storage.OID
+	// Conflicts lists paths of files that could not be merged cleanly
+	Conflicts []string
+}
+
+// Clean reports whether merge completed without conflicts
+func (r Result) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// Trees merges changes made to base tree in ours and theirs trees, file by file.
+// Zero OID can be used for any of the trees to denote an empty tree.
+// Conflict markers of conflicting files are labeled with oursLabel and theirsLabel
+func Trees(base, ours, theirs storage.OID, oursLabel, theirsLabel string) (Result, error) {
+	baseFiles, err := plumbing.ReadTreeFiles(base)
+	if err != nil {
+		return Result{}, err
+	}
+	oursFiles, err := plumbing.ReadTreeFiles(ours)
+	if err != nil {
+		return Result{}, err
+	}
+	theirsFiles, err := plumbing.ReadTreeFiles(theirs)
+	if err != nil {
+		return Result{}, err
+	}
+	paths := make(map[string]bool)
+	for _, files := range []map[string]storage.OID{baseFiles, oursFiles, theirsFiles} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+	var result Result
+	merged := make(map[string]storage.OID)
+	for path := range paths {
+		b, o, t := baseFiles[path], oursFiles[path], theirsFiles[path]
+		oid, clean, err := mergeFile(b, o, t, oursLabel, theirsLabel)
+		if err != nil {
+			return Result{}, err
+		}
+		if !clean {
+			result.Conflicts = append(result.Conflicts, path)
+		}
+		if oid != storage.ZeroOID {
+			merged[path] = oid
+		}
+	}
+	sort.Strings(result.Conflicts)
+	result.Tree, err = plumbing.WriteTreeFiles(merged)
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// mergeFile merges a single file given as blob ids, zero id means that
+// the file is absent. Return id of the merged blob, or zero id if
+// the file should be absent in the result
+func mergeFile(base, ours, theirs storage.OID, oursLabel, theirsLabel string) (storage.OID, bool, error) {
+	switch {
+	case ours == theirs:
+		return ours, true, nil
+	case base == ours:
+		return theirs, true, nil
+	case base == theirs:
+		return ours, true, nil
+	case ours == storage.ZeroOID:
+		// deleted in ours, modified in theirs: keep the modified version
+		return theirs, false, nil
+	case theirs == storage.ZeroOID:
+		return ours, false, nil
+	}
+	var baseData []byte
+	if base != storage.ZeroOID {
+		var err error
+		baseData, err = plumbing.ReadBlob(base)
+		if err != nil {
+			return storage.ZeroOID, false, err
+		}
+	}
+	oursData, err := plumbing.ReadBlob(ours)
+	if err != nil {
+		return storage.ZeroOID, false, err
+	}
+	theirsData, err := plumbing.ReadBlob(theirs)
+	if err != nil {
+		return storage.ZeroOID, false, err
+	}
+	merged, clean := Files(baseData, oursData, theirsData, oursLabel, theirsLabel)
+	oid, err := storage.StoreObject(merged, storage.TypeBlob)
+	return oid, clean, err
+}
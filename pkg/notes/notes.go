@@ -0,0 +1,157 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Ref is the reference notes are kept under. It points to a commit whose
+// tree has a file per annotated object, named by the object id and holding
+// the note. Every change of the notes is a new commit, so their history
+// is kept the same way as the history of files
+const Ref = "refs/notes/commits"
+
+// ErrNoNote is returned when object has no note
+var ErrNoNote = errors.New("no note found")
+
+// ErrNoteExists is returned when adding a note to an object that already has one
+var ErrNoteExists = errors.New("note already exists")
+
+// Note is a note attached to an object
+type Note struct {
+	// Object is the annotated object, Blob holds the text of the note
+	Object storage.OID
+	Blob   storage.OID
+}
+
+// Get returns note attached to the object
+func Get(object storage.OID) (string, error) {
+	files, _, err := readCached()
+	if err != nil {
+		return "", err
+	}
+	blob, ok := files[object.String()]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", object, ErrNoNote)
+	}
+	data, err := plumbing.ReadBlob(blob)
+	return string(data), err
+}
+
+// Add attaches note to the object. If the object already has a note,
+// ErrNoteExists is returned, unless force is set, then the note is replaced
+func Add(object storage.OID, text string, force bool) error {
+	files, parent, err := read()
+	if err != nil {
+		return err
+	}
+	if _, ok := files[object.String()]; ok && !force {
+		return fmt.Errorf("%s: %w", object, ErrNoteExists)
+	}
+	blob, err := storage.StoreObject([]byte(text), storage.TypeBlob)
+	if err != nil {
+		return err
+	}
+	files[object.String()] = blob
+	return write(files, parent, "Notes added by 'gitik notes add'")
+}
+
+// Remove removes note attached to the object
+func Remove(object storage.OID) error {
+	files, parent, err := read()
+	if err != nil {
+		return err
+	}
+	if _, ok := files[object.String()]; !ok {
+		return fmt.Errorf("%s: %w", object, ErrNoNote)
+	}
+	delete(files, object.String())
+	return write(files, parent, "Notes removed by 'gitik notes remove'")
+}
+
+// List returns all notes, ordered by the annotated object id
+func List() ([]Note, error) {
+	files, _, err := readCached()
+	if err != nil {
+		return nil, err
+	}
+	var notes []Note
+	for name, blob := range files {
+		object, err := storage.MakeOID([]byte(name))
+		if err != nil {
+			// not a note, leave it alone
+			continue
+		}
+		notes = append(notes, Note{Object: object, Blob: blob})
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].Object.String() < notes[j].Object.String()
+	})
+	return notes, nil
+}
+
+// read returns files of the current notes tree, which may be modified,
+// and id of the notes commit
+func read() (map[string]storage.OID, storage.OID, error) {
+	files, oid, err := readCached()
+	if err != nil {
+		return nil, storage.ZeroOID, err
+	}
+	result := make(map[string]storage.OID, len(files))
+	for name, blob := range files {
+		result[name] = blob
+	}
+	return result, oid, nil
+}
+
+// the notes tree read last time, reused while the notes ref does not move,
+// e.g. when log shows notes of every commit
+var (
+	cachedOID   storage.OID
+	cachedFiles map[string]storage.OID
+)
+
+// readCached returns files of the current notes tree, that must not be
+// modified, and id of the notes commit
+func readCached() (map[string]storage.OID, storage.OID, error) {
+	oid, err := refs.Read(Ref)
+	if errors.Is(err, refs.ErrNotFound) {
+		return nil, storage.ZeroOID, nil
+	}
+	if err != nil {
+		return nil, storage.ZeroOID, err
+	}
+	if cachedFiles != nil && oid == cachedOID {
+		return cachedFiles, oid, nil
+	}
+	c, err := commit.GetCommit(oid)
+	if err != nil {
+		return nil, storage.ZeroOID, err
+	}
+	files, err := plumbing.ReadTreeFiles(c.Tree)
+	if err != nil {
+		return nil, storage.ZeroOID, err
+	}
+	cachedOID, cachedFiles = oid, files
+	return files, oid, nil
+}
+
+// write commits the notes tree on top of the previous notes commit
+func write(files map[string]storage.OID, parent storage.OID, message string) error {
+	tree, err := plumbing.WriteTreeFiles(files)
+	if err != nil {
+		return err
+	}
+	c := commit.Commit{Tree: tree, Parent: parent, Message: message}
+	oid, err := c.Write()
+	if err != nil {
+		return err
+	}
+	return refs.Update(Ref, oid, "notes: "+message)
+}
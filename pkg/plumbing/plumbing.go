@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 
@@ -52,6 +53,48 @@ func WriteFile(fileName string) (storage.OID, error) {
 	return storage.StoreObject(data, storage.TypeBlob)
 }
 
+// HashFile returns object id WriteFile would store the given file under,
+// without storing it
+func HashFile(fileName string) (storage.OID, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return storage.HashObject(data, storage.TypeBlob), nil
+}
+
+// HashTreeFiles returns ids of the files in the given directory and its
+// subdirectories, keyed by path relative to the directory, the way WriteTree
+// would store them. Files are only hashed, nothing is stored
+func HashTreeFiles(directory string) (map[string]storage.OID, error) {
+	files := make(map[string]storage.OID)
+	err := filepath.Walk(directory, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != directory && IsIgnored(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(directory, p)
+		if err != nil {
+			return err
+		}
+		oid, err := HashFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = oid
+		return nil
+	})
+	return files, err
+}
+
 // WriteTree writes contents of the given directory (relative to the root of the repository)
 // to the object database. Return object id of the stored directory.
 // Recursively writes all files found in the directory
@@ -62,7 +105,7 @@ func WriteTree(directory string) (storage.OID, error) {
 	}
 	var entries []treeEntry
 	for _, f := range files {
-		if isIgnored(f.Name()) {
+		if IsIgnored(f.Name()) {
 			continue
 		}
 		var entry treeEntry
@@ -98,7 +141,7 @@ func WriteTree(directory string) (storage.OID, error) {
 // the write happens, but the ignored files are omitted
 func ReadTree(oid storage.OID) error {
 	entries, err := readTreeEntries(oid, ".")
-	if err != nil {
+	if err != nil && err != errEmptyTree {
 		return err
 	}
 	err = emptyDir(".")
@@ -125,6 +168,172 @@ func ReadTree(oid storage.OID) error {
 	return nil
 }
 
+// UpdateTree changes working tree from the state of one tree to the state
+// of the other, touching only the files that differ between them. The files
+// absent from the target are removed, along with the directories left empty.
+// Update can be repeated, so an interrupted one is finished by running it again,
+// or undone by updating back from the target
+func UpdateTree(from, to storage.OID) error {
+	fromFiles, err := ReadTreeFiles(from)
+	if err != nil {
+		return err
+	}
+	toFiles, err := ReadTreeFiles(to)
+	if err != nil {
+		return err
+	}
+	var removed, written []string
+	for name, oid := range fromFiles {
+		if target, ok := toFiles[name]; !ok || target != oid {
+			removed = append(removed, name)
+		}
+	}
+	for name, oid := range toFiles {
+		if source, ok := fromFiles[name]; !ok || source != oid {
+			written = append(written, name)
+		}
+	}
+	// remove first, so that a file can be replaced by a directory
+	// with the same name and the other way round
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	for _, name := range removed {
+		if _, ok := toFiles[name]; ok {
+			continue
+		}
+		err = os.Remove(name)
+		// the file may be already removed, along with its directory
+		if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return err
+		}
+		removeEmptyParents(name)
+	}
+	sort.Strings(written)
+	dirPerm := os.ModeDir | 0755
+	for _, name := range written {
+		dirPath, _ := path.Split(name)
+		if dirPath != "" {
+			err = os.MkdirAll(dirPath, dirPerm)
+			if err != nil {
+				return err
+			}
+		}
+		data, err := readObject(toFiles[name], storage.TypeBlob)
+		if err != nil {
+			return err
+		}
+		err = storage.WriteFile(name, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmptyParents removes directories of the file that became empty
+func removeEmptyParents(name string) {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// ReadTreeFiles returns all the files stored in the tree, recursively, keyed by
+// their path relative to the root of the tree. Zero OID is treated as an empty tree
+func ReadTreeFiles(oid storage.OID) (map[string]storage.OID, error) {
+	files := make(map[string]storage.OID)
+	if oid == storage.ZeroOID {
+		return files, nil
+	}
+	entries, err := readTreeEntries(oid, ".")
+	if err == errEmptyTree {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		files[strings.TrimPrefix(entry.name, "./")] = entry.oid
+	}
+	return files, nil
+}
+
+// WriteTreeFiles writes a tree consisting of the given files, keyed by their
+// path relative to the root of the tree, to the object database, creating
+// subtrees as needed. This is ReadTreeFiles counterpart
+func WriteTreeFiles(files map[string]storage.OID) (storage.OID, error) {
+	blobs := make(map[string]storage.OID)
+	subdirs := make(map[string]map[string]storage.OID)
+	for name, oid := range files {
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) == 1 {
+			blobs[name] = oid
+			continue
+		}
+		if subdirs[parts[0]] == nil {
+			subdirs[parts[0]] = make(map[string]storage.OID)
+		}
+		subdirs[parts[0]][parts[1]] = oid
+	}
+	var entries []treeEntry
+	for name, oid := range blobs {
+		entries = append(entries, treeEntry{name: name, oid: oid, otype: storage.TypeBlob})
+	}
+	for name, subdir := range subdirs {
+		oid, err := WriteTreeFiles(subdir)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		entries = append(entries, treeEntry{name: name, oid: oid, otype: storage.TypeTree})
+	}
+	// keep the same order WriteTree produces, so that equal trees have equal ids
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+	return storage.StoreObject([]byte(strings.Join(lines, "\n")), storage.TypeTree)
+}
+
+// ErrPathNotFound is returned when tree has no entry under given path
+var ErrPathNotFound = errors.New("path not found in tree")
+
+// LookupPath finds an entry under given path in the tree, descending into
+// subtrees, and returns its id and type. Empty path refers to the tree itself
+func LookupPath(tree storage.OID, path string) (storage.OID, storage.ObjectType, error) {
+	oid, otype := tree, storage.TypeTree
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if otype != storage.TypeTree {
+			return storage.ZeroOID, "", fmt.Errorf("%s: %w", path, ErrPathNotFound)
+		}
+		data, err := readObject(oid, storage.TypeTree)
+		if err != nil {
+			return storage.ZeroOID, "", err
+		}
+		found := false
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			entry, err := parseEntry(line)
+			if err != nil {
+				return storage.ZeroOID, "", err
+			}
+			if entry.name == name {
+				oid, otype, found = entry.oid, entry.otype, true
+				break
+			}
+		}
+		if !found {
+			return storage.ZeroOID, "", fmt.Errorf("%s: %w", path, ErrPathNotFound)
+		}
+	}
+	return oid, otype, nil
+}
+
 var errEmptyTree = errors.New("empty tree")
 
 func readTreeEntries(oid storage.OID, path string) ([]treeEntry, error) {
@@ -168,7 +377,8 @@ func readTreeEntries(oid storage.OID, path string) ([]treeEntry, error) {
 // for testing purposes in the same directory, remove when done
 var blacklist = []string{"gitik", ".git"}
 
-func isIgnored(path string) bool {
+// IsIgnored reports whether the file is never stored in the repository
+func IsIgnored(path string) bool {
 	for _, item := range blacklist {
 		if strings.Contains(path, item) {
 			return true
@@ -184,7 +394,7 @@ func emptyDir(directory string) error {
 	}
 	for _, f := range files {
 		fullPath := filepath.Join(directory, f.Name())
-		if isIgnored(f.Name()) {
+		if IsIgnored(f.Name()) {
 			continue
 		}
 		if f.IsDir() {
@@ -210,6 +420,11 @@ func emptyDir(directory string) error {
 	return nil
 }
 
+// ReadBlob returns contents of the file stored under given oid
+func ReadBlob(oid storage.OID) ([]byte, error) {
+	return readObject(oid, storage.TypeBlob)
+}
+
 func readObject(oid storage.OID, expectedType storage.ObjectType) ([]byte, error) {
 	obj, err := storage.GetObject(oid)
 	if err != nil {
@@ -0,0 +1,176 @@
+package refs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/identity"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// LogEntry is a single record of the reflog: a reference moved from Old
+// object to New one, by whom and when it happened and why
+type LogEntry struct {
+	Old    storage.OID
+	New    storage.OID
+	Who    identity.Signature
+	Reason string
+}
+
+func newLogEntry(old, oid storage.OID, reason string) LogEntry {
+	return LogEntry{Old: old, New: oid, Who: identity.Now(), Reason: reason}
+}
+
+// Encode entry into a single reflog line, decodeLogEntry counterpart
+func (e LogEntry) Encode() []byte {
+	reason := strings.ReplaceAll(e.Reason, "\n", " ")
+	return []byte(fmt.Sprintf("%s %s %s\t%s\n", e.Old, e.New, e.Who, reason))
+}
+
+// ErrInvalidLogEntry is returned when reflog contains malformed line
+var ErrInvalidLogEntry = errors.New("invalid reflog entry")
+
+func decodeLogEntry(line []byte) (LogEntry, error) {
+	var entry LogEntry
+	parts := bytes.SplitN(line, []byte("\t"), 2)
+	if len(parts) != 2 {
+		return entry, ErrInvalidLogEntry
+	}
+	entry.Reason = string(parts[1])
+	fields := bytes.SplitN(parts[0], []byte(" "), 3)
+	if len(fields) != 3 {
+		return entry, ErrInvalidLogEntry
+	}
+	var err error
+	entry.Old, err = storage.MakeOID(fields[0])
+	if err != nil {
+		return entry, err
+	}
+	entry.New, err = storage.MakeOID(fields[1])
+	if err != nil {
+		return entry, err
+	}
+	entry.Who, err = identity.ParseSignature(string(fields[2]))
+	if err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// ReadLog returns reflog of the reference with given name, ordered
+// from the newest entry to the oldest one, so that n-th element of
+// the result corresponds to name@{n}
+func ReadLog(name string) ([]LogEntry, error) {
+	path, err := logPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []LogEntry
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		entry, err := decodeLogEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// ErrNoLogEntry is returned when reflog does not have requested entry
+var ErrNoLogEntry = errors.New("reflog entry not found")
+
+// ReadLogEntry returns object id the reference pointed to n moves ago,
+// i.e. resolves name@{n}. name@{0} is the current value of the reference
+func ReadLogEntry(name string, n int) (storage.OID, error) {
+	entries, err := ReadLog(name)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if n < 0 || n >= len(entries) {
+		return storage.ZeroOID, fmt.Errorf("%s@{%d}: %w", name, n, ErrNoLogEntry)
+	}
+	return entries[n].New, nil
+}
+
+// DropLogEntry removes name@{n} from the reflog and points the reference
+// to the newest of the remaining entries. The reference is deleted
+// if no entries remain. This is what makes reflog of refs/stash a stack
+func DropLogEntry(name string, n int) error {
+	entries, err := ReadLog(name)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n >= len(entries) {
+		return fmt.Errorf("%s@{%d}: %w", name, n, ErrNoLogEntry)
+	}
+	entries = append(entries[:n], entries[n+1:]...)
+	if len(entries) == 0 {
+		return Delete(name)
+	}
+	var buf bytes.Buffer
+	for i := len(entries) - 1; i >= 0; i-- {
+		buf.Write(entries[i].Encode())
+	}
+	path, err := logPath(name)
+	if err != nil {
+		return err
+	}
+	err = storage.WriteFile(path, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return writeRef(name, []byte(entries[0].New.String()))
+}
+
+// appendLog adds an entry to the end of the reflog of the given reference.
+// Reflog is append-only: regular operations never rewrite its entries,
+// they can only be removed explicitly with DropLogEntry
+func appendLog(name string, entry LogEntry) (err error) {
+	path, err := logPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		cerr := file.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+	_, err = file.Write(entry.Encode())
+	return
+}
+
+func logPath(name string) (string, error) {
+	err := checkName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(constants.GitDir, "logs", filepath.FromSlash(name)), nil
+}
@@ -0,0 +1,230 @@
+package refs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Head is the name of the reference to the currently checked out commit
+const Head = constants.HeadName
+
+// refsDir is the directory all references except HEAD are stored in
+const refsDir = "refs"
+
+// symbolicPrefix marks references that point to other references instead of
+// objects, e.g. HEAD pointing to a branch contains "ref: refs/heads/master"
+const symbolicPrefix = "ref: "
+
+// ErrNotFound is returned when reference does not exist or is empty
+var ErrNotFound = errors.New("reference not found")
+
+// ErrInvalidName is returned for reference names that would point
+// outside of the git directory
+var ErrInvalidName = errors.New("invalid reference name")
+
+// Read returns object id the reference with given name points to,
+// following symbolic references
+func Read(name string) (storage.OID, error) {
+	target, err := Target(name)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	data, err := readRef(target)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return storage.MakeOID(data)
+}
+
+// Target returns name of the reference that actually stores object id
+// for the given reference. For regular references it's the name itself,
+// for symbolic references it's the name of the reference they point to
+func Target(name string) (string, error) {
+	for i := 0; i < maxSymbolicDepth; i++ {
+		data, err := readRef(name)
+		if errors.Is(err, ErrNotFound) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if !bytes.HasPrefix(data, []byte(symbolicPrefix)) {
+			return name, nil
+		}
+		name = strings.TrimSpace(string(data[len(symbolicPrefix):]))
+	}
+	return "", fmt.Errorf("refs: too many levels of symbolic references: %s", name)
+}
+
+const maxSymbolicDepth = 5
+
+// Update points reference with given name to the given object and records
+// the change in the reflog, using reason as the description. Symbolic
+// references are followed, and both the symbolic reference and the reference
+// it points to get a reflog entry
+func Update(name string, oid storage.OID, reason string) error {
+	target, err := Target(name)
+	if err != nil {
+		return err
+	}
+	old, err := Read(target)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	err = writeRef(target, []byte(oid.String()))
+	if err != nil {
+		return err
+	}
+	entry := newLogEntry(old, oid, reason)
+	if target != name {
+		err = appendLog(name, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return appendLog(target, entry)
+}
+
+// Detach points HEAD directly at the given object, even if it currently
+// points to a branch, and records the change in the HEAD reflog
+func Detach(oid storage.OID, reason string) error {
+	old, err := Read(Head)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	err = writeRef(Head, []byte(oid.String()))
+	if err != nil {
+		return err
+	}
+	return appendLog(Head, newLogEntry(old, oid, reason))
+}
+
+// SetSymbolic points reference with given name to another reference, target,
+// e.g. attaches HEAD to a branch. The change is recorded in the reflog
+func SetSymbolic(name, target, reason string) error {
+	old, err := Read(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	oid, err := Read(target)
+	if err != nil {
+		return err
+	}
+	err = writeRef(name, []byte(symbolicPrefix+target))
+	if err != nil {
+		return err
+	}
+	return appendLog(name, newLogEntry(old, oid, reason))
+}
+
+// List returns names of all references under refs/, sorted by name
+func List() ([]string, error) {
+	root := filepath.Join(constants.GitDir, refsDir)
+	var names []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(constants.GitDir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	return names, err
+}
+
+// ShortName returns name of the reference without the prefix
+// common for its kind, e.g. "master" for refs/heads/master
+func ShortName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// Delete removes reference with given name together with its reflog
+func Delete(name string) error {
+	refFile, err := refPath(name)
+	if err != nil {
+		return err
+	}
+	logFile, err := logPath(name)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{refFile, logFile} {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRef(name string) ([]byte, error) {
+	path, err := refPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func writeRef(name string, data []byte) error {
+	path, err := refPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(path, data)
+}
+
+func refPath(name string) (string, error) {
+	err := checkName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(constants.GitDir, filepath.FromSlash(name)), nil
+}
+
+// checkName rejects names that are absolute or contain "..",
+// as their files would be outside of the git directory
+func checkName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || filepath.IsAbs(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// absolute date formats understood by ParseDate
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// ParseDate parses a point in time given either as an absolute date, like
+// "2020-12-31" or "2020-12-31 23:59:59", or relative to now, like "now",
+// "yesterday", "3 days ago", "2.weeks.ago" or "1 month ago". Dates without
+// a time zone are in the local time zone
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	switch s {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+	fields := strings.Fields(strings.ReplaceAll(s, ".", " "))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", s)
+		}
+		unit := strings.TrimSuffix(fields[1], "s")
+		switch unit {
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		case "year":
+			return now.AddDate(-n, 0, 0), nil
+		}
+		if d, ok := dateUnits[unit]; ok {
+			return now.Add(-time.Duration(n) * d), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,303 @@
+package revision
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// ErrUnknownRevision is returned when revision does not name any object
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// ErrAmbiguous is returned when abbreviated object id matches several objects
+var ErrAmbiguous = errors.New("ambiguous object id")
+
+// minAbbrev is the shortest prefix of an object id that is accepted
+const minAbbrev = 4
+
+// refPrefixes are tried in order when looking up a reference by a short name,
+// e.g. "stash" is refs/stash and "master" is refs/heads/master
+var refPrefixes = []string{"refs/", "refs/tags/", "refs/heads/"}
+
+// pseudoRefs are the references kept directly in the git directory. Other
+// files there, like the index, are never looked up as references
+var pseudoRefs = []string{refs.Head, constants.CherryPickHeadName}
+
+// Resolve returns id of the object named by the revision. Revision starts
+// with a name, that is HEAD (or @), a reference, like a branch or a tag, or
+// an object id, possibly abbreviated. The name can be followed by:
+//   - @{n}, the value the reference had n moves ago, from the reflog
+//   - @{date}, the value the reference had at the given point in time
+//   - ~n, n-th ancestor of a commit following the parents, ~ is ~1
+//   - ^n, n-th parent of a commit, ^ is ^1, ^0 is the commit itself
+//   - :path, a file or a directory under the path in the commit's tree
+//
+// For example HEAD~2^:pkg/main.go or master@{yesterday}
+func Resolve(rev string) (storage.OID, error) {
+	rev, path, hasPath := splitPath(rev)
+	name, suffix := splitName(rev)
+	oid, suffix, err := resolveName(name, suffix)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	for len(suffix) > 0 {
+		op := suffix[0]
+		digits := 1
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 1 {
+			n, err = strconv.Atoi(suffix[1:digits])
+			if err != nil {
+				return storage.ZeroOID, fmt.Errorf("%s: %w", rev, err)
+			}
+		}
+		suffix = suffix[digits:]
+		switch op {
+		case '~':
+			oid, err = ancestor(oid, n)
+		case '^':
+			oid, err = parent(oid, n)
+		default:
+			err = fmt.Errorf("unexpected %q", op)
+		}
+		if err != nil {
+			return storage.ZeroOID, fmt.Errorf("%s: %w", rev, err)
+		}
+	}
+	if !hasPath {
+		return oid, nil
+	}
+	tree, err := peelTree(oid)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	oid, _, err = plumbing.LookupPath(tree, path)
+	return oid, err
+}
+
+// ResolveCommit returns the commit named by the revision
+func ResolveCommit(rev string) (commit.Commit, error) {
+	oid, err := Resolve(rev)
+	if err != nil {
+		return commit.Commit{}, err
+	}
+	err = expectType(oid, storage.TypeCommit)
+	if err != nil {
+		return commit.Commit{}, fmt.Errorf("%s: %w", rev, err)
+	}
+	return commit.GetCommit(oid)
+}
+
+// ResolveTree returns id of the tree named by the revision,
+// for commits it's the tree of the commit
+func ResolveTree(rev string) (storage.OID, error) {
+	oid, err := Resolve(rev)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return peelTree(oid)
+}
+
+// RefName returns full name of the existing reference named by a short
+// name, e.g. refs/stash for stash. HEAD and @ stand for HEAD
+func RefName(name string) (string, error) {
+	if name == "" || name == "@" || name == refs.Head {
+		return refs.Head, nil
+	}
+	var candidates []string
+	if strings.HasPrefix(name, "refs/") || isPseudoRef(name) {
+		candidates = append(candidates, name)
+	}
+	for _, prefix := range refPrefixes {
+		candidates = append(candidates, prefix+name)
+	}
+	for _, candidate := range candidates {
+		if _, err := refs.Read(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", name, ErrUnknownRevision)
+}
+
+func isPseudoRef(name string) bool {
+	for _, pseudo := range pseudoRefs {
+		if name == pseudo {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPath splits "rev:path" into revision and path. Colons inside
+// of @{...} are a part of the revision, as dates may contain them
+func splitPath(rev string) (string, string, bool) {
+	depth := 0
+	for i, c := range rev {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				return rev[:i], rev[i+1:], true
+			}
+		}
+	}
+	return rev, "", false
+}
+
+// splitName splits revision into the leading name and the rest of it
+func splitName(rev string) (string, string) {
+	end := strings.IndexAny(rev, "~^")
+	if at := strings.Index(rev, "@{"); at != -1 && (end == -1 || at < end) {
+		end = at
+	}
+	if end == -1 {
+		return rev, ""
+	}
+	return rev[:end], rev[end:]
+}
+
+// resolveName returns object named by name, taking @{...} that may
+// follow it into account. Return the rest of the suffix
+func resolveName(name, suffix string) (storage.OID, string, error) {
+	if strings.HasPrefix(suffix, "@{") {
+		end := strings.Index(suffix, "}")
+		if end == -1 {
+			return storage.ZeroOID, "", errors.New("unterminated @{")
+		}
+		refName, err := RefName(name)
+		if err != nil {
+			return storage.ZeroOID, "", err
+		}
+		oid, err := resolveLog(refName, suffix[2:end])
+		return oid, suffix[end+1:], err
+	}
+	if refName, err := RefName(name); err == nil {
+		oid, err := refs.Read(refName)
+		return oid, suffix, err
+	}
+	oid, err := resolveOID(name)
+	return oid, suffix, err
+}
+
+// resolveLog resolves @{n} or @{date} of the reference using its reflog
+func resolveLog(refName, selector string) (storage.OID, error) {
+	if n, err := strconv.Atoi(selector); err == nil {
+		return refs.ReadLogEntry(refName, n)
+	}
+	date, err := ParseDate(selector, time.Now())
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	entries, err := refs.ReadLog(refName)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	for _, entry := range entries {
+		if !entry.Who.When.After(date) {
+			return entry.New, nil
+		}
+	}
+	if len(entries) == 0 {
+		return storage.ZeroOID, fmt.Errorf("%s: %w", refName, refs.ErrNoLogEntry)
+	}
+	oldest := entries[len(entries)-1].Who.When
+	return storage.ZeroOID, fmt.Errorf("log for %s only goes back to %s", refName, oldest.Format(time.RFC1123Z))
+}
+
+// resolveOID returns object with given full or abbreviated id
+func resolveOID(hex string) (storage.OID, error) {
+	if len(hex) < minAbbrev {
+		return storage.ZeroOID, fmt.Errorf("%s: %w", hex, ErrUnknownRevision)
+	}
+	if oid, err := storage.MakeOID([]byte(hex)); err == nil {
+		return oid, nil
+	}
+	found, err := storage.FindObjects(strings.ToLower(hex))
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	switch len(found) {
+	case 0:
+		return storage.ZeroOID, fmt.Errorf("%s: %w", hex, ErrUnknownRevision)
+	case 1:
+		return found[0], nil
+	default:
+		return storage.ZeroOID, fmt.Errorf("%s: %w", hex, ErrAmbiguous)
+	}
+}
+
+func ancestor(oid storage.OID, n int) (storage.OID, error) {
+	for i := 0; i < n; i++ {
+		var err error
+		oid, err = parent(oid, 1)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+	}
+	return oid, nil
+}
+
+// parent returns n-th parent of the commit, counting from 1, and
+// the commit itself for 0. Commits made by gitik have at most one parent,
+// merge commits made by other tools may have more
+func parent(oid storage.OID, n int) (storage.OID, error) {
+	err := expectType(oid, storage.TypeCommit)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if n == 0 {
+		return oid, nil
+	}
+	c, err := commit.GetCommit(oid)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	parents := c.Parents()
+	if n > len(parents) {
+		return storage.ZeroOID, fmt.Errorf("commit %s has no parent %d", oid, n)
+	}
+	return parents[n-1], nil
+}
+
+func peelTree(oid storage.OID) (storage.OID, error) {
+	obj, err := storage.GetObject(oid)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	switch obj.ObjType {
+	case storage.TypeTree:
+		return oid, nil
+	case storage.TypeCommit:
+		c, err := commit.GetCommit(oid)
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		return c.Tree, nil
+	default:
+		return storage.ZeroOID, fmt.Errorf("%s is a %s, not a tree", oid, obj.ObjType)
+	}
+}
+
+func expectType(oid storage.OID, expected storage.ObjectType) error {
+	obj, err := storage.GetObject(oid)
+	if err != nil {
+		return err
+	}
+	if obj.ObjType != expected {
+		return fmt.Errorf("%s is a %s, not a %s", oid, obj.ObjType, expected)
+	}
+	return nil
+}
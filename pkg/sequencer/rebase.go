@@ -0,0 +1,350 @@
+package sequencer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// rebase state, in addition to the common files: the reference that was
+// checked out when rebase started, the commit rebase is done onto, and the
+// step that stopped because of a conflict and has to be finished on continue
+const (
+	rebaseDir    = "rebase-merge"
+	headNameFile = "head-name"
+	ontoFile     = "onto"
+	stoppedFile  = "stopped"
+	editTodoFile = "rebase-todo"
+)
+
+// ErrUpToDate is returned when there is nothing to rebase
+var ErrUpToDate = errors.New("current branch is up to date")
+
+// ErrNothingToDo is returned when user removed every step from the todo list
+var ErrNothingToDo = errors.New("nothing to do")
+
+// ErrStopped is returned when rebase stops at a commit marked for editing
+var ErrStopped = errors.New("stopped for editing, amend the commit and continue the rebase")
+
+// Rebase replays commits that are reachable from HEAD, but not from upstream,
+// on top of upstream, one by one, and moves HEAD (and the branch it points
+// to, if any) to the last of them. If interactive is set, user can edit the
+// list of steps before they are done.
+// If one of the steps conflicts, commit.ConflictError is returned and the
+// rebase can be resumed with RebaseContinue once conflicts are resolved,
+// the step skipped with RebaseSkip, or the whole rebase cancelled with RebaseAbort.
+// Rebase is refused with commit.DirtyTreeError if tracked files have local changes
+func Rebase(upstream storage.OID, interactive bool) error {
+	err := checkIdle()
+	if err != nil {
+		return fmt.Errorf("rebase: %w", err)
+	}
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	changed, err := commit.LocalChanges()
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("rebase: %w", commit.DirtyTreeError{Paths: changed})
+	}
+	onto, err := commit.GetCommit(upstream)
+	if err != nil {
+		return err
+	}
+	todo, upToDate, err := uniqueCommits(head.OID, onto.OID)
+	if err != nil {
+		return err
+	}
+	if upToDate && !interactive {
+		return ErrUpToDate
+	}
+	headName, err := refs.Target(refs.Head)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(statePath(rebaseDir), 0755)
+	if err != nil {
+		return err
+	}
+	err = storage.WriteFile(statePath(rebaseDir, headNameFile), []byte(headName))
+	if err == nil {
+		err = writeOID(statePath(rebaseDir, headFile), head.OID)
+	}
+	if err == nil {
+		err = writeOID(statePath(rebaseDir, ontoFile), onto.OID)
+	}
+	if err == nil && interactive {
+		todo, err = editTodo(statePath(rebaseDir, editTodoFile), todo)
+		if err == nil && len(todo) == 0 {
+			err = ErrNothingToDo
+		}
+		if err == nil && (todo[0].Action == ActionSquash || todo[0].Action == ActionFixup) {
+			err = fmt.Errorf("cannot %s without a previous commit", todo[0].Action)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(statePath(rebaseDir))
+		return err
+	}
+	err = commit.UpdateWorkingTree(onto.Tree, false)
+	if err != nil {
+		os.RemoveAll(statePath(rebaseDir))
+		return err
+	}
+	err = refs.Detach(onto.OID, fmt.Sprintf("rebase (start): checkout %s", onto.OID))
+	if err != nil {
+		os.RemoveAll(statePath(rebaseDir))
+		return err
+	}
+	return runRebase(todo)
+}
+
+// RebaseContinue resumes rebase stopped by a conflict or for editing.
+// The stopped step is finished using the current tree, the index or
+// the working tree if there is no index, and the rest of the steps is done.
+// If some of the conflicts are not resolved yet, commit.ConflictError
+// listing them is returned
+func RebaseContinue() error {
+	if !inProgress(rebaseDir) {
+		return fmt.Errorf("rebase: %w", ErrNotInProgress)
+	}
+	err := checkResolved()
+	if err != nil {
+		return err
+	}
+	todo, err := readTodo(statePath(rebaseDir, todoFile))
+	if err != nil {
+		return err
+	}
+	stopped, err := readTodo(statePath(rebaseDir, stoppedFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(stopped) > 0 {
+		tree, err := commit.CurrentTree()
+		if err != nil {
+			return err
+		}
+		err = finishStep(stopped[0], tree)
+		if err != nil {
+			return err
+		}
+	}
+	return runRebase(todo)
+}
+
+// RebaseSkip drops the step rebase stopped at, together with any changes
+// made to the working tree, and resumes the rebase
+func RebaseSkip() error {
+	if !inProgress(rebaseDir) {
+		return fmt.Errorf("rebase: %w", ErrNotInProgress)
+	}
+	todo, err := readTodo(statePath(rebaseDir, todoFile))
+	if err != nil {
+		return err
+	}
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	err = head.Reset(commit.ResetHard)
+	if err != nil {
+		return err
+	}
+	err = clearStopped()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return runRebase(todo)
+}
+
+// RebaseAbort cancels rebase, returning HEAD and working tree
+// to the state before the rebase started
+func RebaseAbort() error {
+	if !inProgress(rebaseDir) {
+		return fmt.Errorf("rebase: %w", ErrNotInProgress)
+	}
+	err := resetTo(statePath(rebaseDir, headFile))
+	if err != nil {
+		return err
+	}
+	headName, err := ioutil.ReadFile(statePath(rebaseDir, headNameFile))
+	if err != nil {
+		return err
+	}
+	if string(headName) != refs.Head {
+		reason := fmt.Sprintf("rebase (abort): returning to %s", headName)
+		err = refs.SetSymbolic(refs.Head, string(headName), reason)
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(statePath(rebaseDir))
+}
+
+// uniqueCommits returns pick steps for the commits reachable from head but
+// not from upstream, oldest first. upToDate reports whether upstream
+// is already reachable from head
+func uniqueCommits(head, upstream storage.OID) (todo []Step, upToDate bool, err error) {
+	base, err := commit.MergeBase(head, upstream)
+	if err != nil {
+		return nil, false, err
+	}
+	commits, err := commit.LogRange([]storage.OID{head}, []storage.OID{upstream})
+	if err != nil {
+		return nil, false, err
+	}
+	for _, c := range commits {
+		todo = append([]Step{{Action: ActionPick, OID: c.OID}}, todo...)
+	}
+	return todo, base == upstream, nil
+}
+
+// runRebase does steps one by one, saving the rest of the todo list before
+// each of them, so that the rebase can be resumed if the step fails
+func runRebase(todo []Step) error {
+	for len(todo) > 0 {
+		step := todo[0]
+		todo = todo[1:]
+		err := writeTodo(statePath(rebaseDir, todoFile), todo)
+		if err != nil {
+			return err
+		}
+		if step.Action == ActionDrop {
+			continue
+		}
+		err = doStep(step)
+		if refused(err) {
+			// the step is not done, keep it for the next attempt
+			werr := writeTodo(statePath(rebaseDir, todoFile), append([]Step{step}, todo...))
+			if werr == nil {
+				werr = os.Remove(statePath(rebaseDir, stoppedFile))
+			}
+			if werr != nil && !errors.Is(werr, os.ErrNotExist) {
+				return werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if step.Action == ActionEdit {
+			return ErrStopped
+		}
+	}
+	return finishRebase()
+}
+
+// doStep applies changes of the step's commit to HEAD and commits them.
+// If the step cannot be finished, it's remembered as stopped
+func doStep(step Step) error {
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	c, err := commit.GetCommit(step.OID)
+	if err != nil {
+		return err
+	}
+	if c.Parent == head.OID && (step.Action == ActionPick || step.Action == ActionEdit) {
+		// commit already is on top of HEAD, reuse it instead of making a copy
+		err = commit.UpdateWorkingTree(c.Tree, false)
+		if err != nil {
+			return err
+		}
+		return refs.Detach(c.OID, fmt.Sprintf("rebase (%s): %s", step.Action, c.Subject()))
+	}
+	err = writeTodo(statePath(rebaseDir, stoppedFile), []Step{step})
+	if err != nil {
+		return err
+	}
+	tree, err := commit.ApplyChanges(c)
+	if err != nil {
+		return err
+	}
+	return finishStep(step, tree)
+}
+
+// finishStep commits the tree produced by the step, as a new commit
+// or by melding it into HEAD, depending on the action. Steps whose changes
+// are already in HEAD are dropped
+func finishStep(step Step, tree storage.OID) error {
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	if tree == head.Tree && step.Action != ActionSquash && step.Action != ActionFixup {
+		return clearStopped()
+	}
+	c, err := commit.GetCommit(step.OID)
+	if err != nil {
+		return err
+	}
+	result := commit.Commit{Tree: tree, Parent: head.OID, Author: c.Author, Message: c.Message}
+	switch step.Action {
+	case ActionReword:
+		result.Message, err = commit.EditMessage(c.Message, "")
+	case ActionSquash, ActionFixup:
+		result.Parent = head.Parent
+		result.Author = head.Author
+		result.Message = head.Message
+		if step.Action == ActionSquash {
+			result.Message, err = commit.EditMessage(head.Message+"\n\n"+c.Message, "")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_, err = result.Save(fmt.Sprintf("rebase (%s)", step.Action))
+	if err != nil {
+		return err
+	}
+	return clearStopped()
+}
+
+// clearStopped forgets the step rebase stopped at, together with
+// its conflicts, once the step is finished or skipped
+func clearStopped() error {
+	err := commit.ClearPending()
+	if err != nil {
+		return err
+	}
+	return os.Remove(statePath(rebaseDir, stoppedFile))
+}
+
+// finishRebase moves the branch that was checked out when rebase started
+// to the rebased commits and attaches HEAD back to it
+func finishRebase() error {
+	headName, err := ioutil.ReadFile(statePath(rebaseDir, headNameFile))
+	if err != nil {
+		return err
+	}
+	if string(headName) != refs.Head {
+		head, err := commit.GetHead()
+		if err != nil {
+			return err
+		}
+		onto, err := readOID(statePath(rebaseDir, ontoFile))
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("rebase (finish): %s onto %s", headName, onto)
+		err = refs.Update(string(headName), head.OID, reason)
+		if err != nil {
+			return err
+		}
+		reason = fmt.Sprintf("rebase (finish): returning to %s", headName)
+		err = refs.SetSymbolic(refs.Head, string(headName), reason)
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(statePath(rebaseDir))
+}
@@ -0,0 +1,207 @@
+package sequencer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/constants"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// ErrInProgress is returned when a new sequence is started while another one
+// has not been finished yet
+var ErrInProgress = errors.New("operation is already in progress, use --continue or --abort")
+
+// ErrNotInProgress is returned when there is no sequence to continue or abort
+var ErrNotInProgress = errors.New("no operation in progress")
+
+// every sequence keeps everything needed to continue or abort it after
+// an interruption in its own state directory. Common files are HEAD
+// at the moment the sequence was started, and steps that are still to be done
+const (
+	cherryPickDir = "sequencer"
+	headFile      = "head"
+	todoFile      = "todo"
+)
+
+// CherryPick applies given commits on top of HEAD one by one. If one of them
+// conflicts, commit.ConflictError is returned and the sequence can be
+// resumed with CherryPickContinue once conflicts are resolved,
+// or cancelled with CherryPickAbort
+func CherryPick(oids []storage.OID) error {
+	err := checkIdle()
+	if err != nil {
+		return fmt.Errorf("cherry-pick: %w", err)
+	}
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	var todo []Step
+	for _, oid := range oids {
+		todo = append(todo, Step{Action: ActionPick, OID: oid})
+	}
+	err = os.MkdirAll(statePath(cherryPickDir), 0755)
+	if err != nil {
+		return err
+	}
+	err = writeOID(statePath(cherryPickDir, headFile), head.OID)
+	if err != nil {
+		return err
+	}
+	err = runPicks(todo)
+	if refused(err) && len(todo) == 1 {
+		// nothing was done, there is nothing to continue or abort
+		os.RemoveAll(statePath(cherryPickDir))
+	}
+	return err
+}
+
+// CherryPickContinue resumes cherry-picks stopped by a conflict. Resolved
+// conflicts are committed, unless they already were, and the rest of
+// the commits is picked. If some of the conflicts are not resolved yet,
+// commit.ConflictError listing them is returned
+func CherryPickContinue() error {
+	if !inProgress(cherryPickDir) {
+		return fmt.Errorf("cherry-pick: %w", ErrNotInProgress)
+	}
+	err := checkResolved()
+	if err != nil {
+		return err
+	}
+	todo, err := readTodo(statePath(cherryPickDir, todoFile))
+	if err != nil {
+		return err
+	}
+	message, err := commit.PendingMessage()
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		_, err = commit.SaveCurrentTree(message, commit.Options{})
+		var empty commit.EmptyCommitError
+		if errors.As(err, &empty) {
+			// conflicts were resolved in favor of HEAD, nothing is left to pick
+			err = commit.ClearPending()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return runPicks(todo)
+}
+
+// CherryPickAbort cancels cherry-picks stopped by a conflict, returning HEAD
+// and working tree to the state before the first of them
+func CherryPickAbort() error {
+	if !inProgress(cherryPickDir) {
+		return fmt.Errorf("cherry-pick: %w", ErrNotInProgress)
+	}
+	err := resetTo(statePath(cherryPickDir, headFile))
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(statePath(cherryPickDir))
+}
+
+// runPicks picks commits one by one, saving the rest of the todo list before
+// each of them, so that the sequence can be resumed if the pick fails
+func runPicks(todo []Step) error {
+	for len(todo) > 0 {
+		step := todo[0]
+		todo = todo[1:]
+		err := writeTodo(statePath(cherryPickDir, todoFile), todo)
+		if err != nil {
+			return err
+		}
+		c, err := commit.GetCommit(step.OID)
+		if err != nil {
+			return err
+		}
+		_, err = commit.CherryPick(c)
+		if refused(err) {
+			// the commit is not picked, keep it for the next attempt
+			werr := writeTodo(statePath(cherryPickDir, todoFile), append([]Step{step}, todo...))
+			if werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(statePath(cherryPickDir))
+}
+
+// refused reports whether the step failed before changing anything,
+// because it would overwrite local changes or untracked files
+func refused(err error) bool {
+	var dirty commit.DirtyTreeError
+	var untracked commit.UntrackedFilesError
+	return errors.As(err, &dirty) || errors.As(err, &untracked)
+}
+
+// checkResolved returns commit.ConflictError if the operation stopped
+// because of conflicts still has unresolved ones
+func checkResolved() error {
+	unresolved, err := commit.UnresolvedConflicts()
+	if err != nil {
+		return err
+	}
+	if len(unresolved) > 0 {
+		return commit.ConflictError{Paths: unresolved}
+	}
+	return nil
+}
+
+// resetTo hard resets HEAD to the commit stored in the file under given path,
+// dropping any pending conflicts
+func resetTo(path string) error {
+	oid, err := readOID(path)
+	if err != nil {
+		return err
+	}
+	c, err := commit.GetCommit(oid)
+	if err != nil {
+		return err
+	}
+	err = c.Reset(commit.ResetHard)
+	if err != nil {
+		return err
+	}
+	return commit.ClearPending()
+}
+
+// checkIdle returns ErrInProgress if any sequence is in progress,
+// only one of them can be run at a time
+func checkIdle() error {
+	if inProgress(cherryPickDir) || inProgress(rebaseDir) {
+		return ErrInProgress
+	}
+	return nil
+}
+
+func inProgress(dir string) bool {
+	_, err := os.Stat(statePath(dir))
+	return err == nil
+}
+
+func readOID(path string) (storage.OID, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return storage.MakeOID(data)
+}
+
+func writeOID(path string, oid storage.OID) error {
+	return storage.WriteFile(path, []byte(oid.String()))
+}
+
+func statePath(dir string, name ...string) string {
+	return filepath.Join(append([]string{constants.GitDir, dir}, name...)...)
+}
@@ -0,0 +1,132 @@
+package sequencer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/editor"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Action is what should be done with a commit in a sequence
+type Action string
+
+const (
+	// ActionPick applies changes of the commit on top of HEAD
+	ActionPick Action = "pick"
+	// ActionReword picks the commit and lets user edit its message
+	ActionReword Action = "reword"
+	// ActionEdit picks the commit and stops, so that user can amend it
+	ActionEdit Action = "edit"
+	// ActionSquash melds the commit into the previous one, combining messages
+	ActionSquash Action = "squash"
+	// ActionFixup melds the commit into the previous one, discarding its message
+	ActionFixup Action = "fixup"
+	// ActionDrop skips the commit
+	ActionDrop Action = "drop"
+)
+
+var actions = []Action{ActionPick, ActionReword, ActionEdit, ActionSquash, ActionFixup, ActionDrop}
+
+// parseAction accepts full action names as well as their first letters
+func parseAction(s string) (Action, error) {
+	for _, a := range actions {
+		if s == string(a) || s == string(a)[:1] {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown action %q", s)
+}
+
+// Step is a single entry of the todo list of a sequence
+type Step struct {
+	Action Action
+	OID    storage.OID
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %s", s.Action, s.OID)
+}
+
+// parseTodo parses todo list, one step per line. Anything after
+// the commit id, such as the subject of the commit, is ignored,
+// as well as blank lines and comments
+func parseTodo(data []byte) ([]Step, error) {
+	var todo []Step
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || bytes.HasPrefix(line, []byte(editor.CommentPrefix)) {
+			continue
+		}
+		fields := bytes.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed todo line: %s", line)
+		}
+		action, err := parseAction(string(fields[0]))
+		if err != nil {
+			return nil, err
+		}
+		oid, err := storage.MakeOID(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		todo = append(todo, Step{Action: action, OID: oid})
+	}
+	return todo, nil
+}
+
+func readTodo(path string) ([]Step, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTodo(data)
+}
+
+func writeTodo(path string, todo []Step) error {
+	var lines []string
+	for _, step := range todo {
+		lines = append(lines, step.String()+"\n")
+	}
+	return storage.WriteFile(path, []byte(strings.Join(lines, "")))
+}
+
+// editTodo lets user edit todo list in the editor. The list is shown
+// with commit subjects and a help message, and read back after editing
+func editTodo(path string, todo []Step) ([]Step, error) {
+	var buf bytes.Buffer
+	for _, step := range todo {
+		c, err := commit.GetCommit(step.OID)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s %s\n", step, c.Subject())
+	}
+	buf.WriteString(todoHelp)
+	err := storage.WriteFile(path, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = editor.Edit(path)
+	if err != nil {
+		return nil, err
+	}
+	return readTodo(path)
+}
+
+const todoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's message
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove a line here THAT COMMIT WILL BE LOST.
+# However, if you remove everything, the rebase will be aborted.
+`
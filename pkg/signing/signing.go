@@ -0,0 +1,151 @@
+package signing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrUnsigned is returned when verifying an object that has no signature
+var ErrUnsigned = errors.New("no signature")
+
+// ErrBadSignature is returned when signature does not match the signed data
+var ErrBadSignature = errors.New("bad signature")
+
+// ErrUntrusted is returned when signature is valid, but made by a key
+// that is not in the trusted keys
+var ErrUntrusted = errors.New("signed by an untrusted key")
+
+// TrustedKey is a public key whose signatures are trusted,
+// together with the name of its owner
+type TrustedKey struct {
+	Key  ed25519.PublicKey
+	Name string
+}
+
+const pemType = "PRIVATE KEY"
+
+// GenerateKey creates a new Ed25519 key pair, writes the private key to the
+// file under given path in PKCS #8 PEM format, the same one that is produced
+// by "openssl genpkey -algorithm ed25519", and returns the public key
+func GenerateKey(path string) (ed25519.PublicKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = pem.Encode(file, &pem.Block{Type: pemType, Bytes: der})
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return public, err
+}
+
+// LoadPrivateKey reads Ed25519 private key written by GenerateKey
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("%s: no %s PEM block found", path, pemType)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return private, nil
+}
+
+// EncodeKey encodes public key the way it's written in the trusted keys
+// and signatures
+func EncodeKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// Sign data with the key. Return signature encoded as a single line,
+// containing the public key and the signature itself
+func Sign(data []byte, key ed25519.PrivateKey) string {
+	public := key.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(key, data)
+	return EncodeKey(public) + " " + base64.StdEncoding.EncodeToString(signature)
+}
+
+// Verify checks that signature produced by Sign matches data, and that it
+// was made by one of the trusted keys. Return the key that made the signature
+func Verify(data []byte, signature string, trusted []TrustedKey) (TrustedKey, error) {
+	if signature == "" {
+		return TrustedKey{}, ErrUnsigned
+	}
+	parts := strings.Fields(signature)
+	if len(parts) != 2 {
+		return TrustedKey{}, ErrBadSignature
+	}
+	public, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return TrustedKey{}, ErrBadSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || !ed25519.Verify(public, data, sig) {
+		return TrustedKey{}, ErrBadSignature
+	}
+	for _, key := range trusted {
+		if bytes.Equal(key.Key, public) {
+			return key, nil
+		}
+	}
+	return TrustedKey{Key: public}, ErrUntrusted
+}
+
+// ReadTrustedKeys reads file with trusted keys, one per line, written as
+// an encoded public key followed by the name of its owner. Empty lines and
+// lines starting with # are ignored. Missing file means no keys are trusted
+func ReadTrustedKeys(path string) ([]TrustedKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []TrustedKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		public, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil || len(public) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: malformed key %q", path, parts[0])
+		}
+		key := TrustedKey{Key: public}
+		if len(parts) == 2 {
+			key.Name = strings.TrimSpace(parts[1])
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
@@ -0,0 +1,184 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
+	"github.com/i-hate-nicknames/gitik/pkg/merge"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Ref is the reference pointing to the most recent stash entry. Its reflog
+// is the stack of all stash entries, stash@{n} is Ref@{n}
+const Ref = "refs/stash"
+
+// ErrNoLocalChanges is returned when there is nothing to stash
+var ErrNoLocalChanges = errors.New("no local changes to save")
+
+// Entry is a single stashed state of the working tree
+type Entry struct {
+	OID     storage.OID
+	Message string
+}
+
+// Push saves local changes to the tracked files as a stash commit on top of
+// HEAD, puts it on top of the stash stack and resets the tracked files and
+// the index to HEAD. Untracked files are neither stashed nor touched.
+// The index, if any, is saved as the second parent of the stash commit,
+// a commit of its own on top of HEAD. If message is empty, it's generated
+// from HEAD
+func Push(message string) (storage.OID, error) {
+	head, err := commit.GetHead()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	tree, err := commit.TrackedWorkingTree()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	staged, err := indexTree()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	if tree == head.Tree && (staged == storage.ZeroOID || staged == head.Tree) {
+		return storage.ZeroOID, ErrNoLocalChanges
+	}
+	on := fmt.Sprintf("%s: %s", head.OID.String()[:7], head.Subject())
+	if message == "" {
+		message = "WIP on " + on
+	} else {
+		message = fmt.Sprintf("On %s: %s", head.OID.String()[:7], message)
+	}
+	c := commit.Commit{Tree: tree, Parent: head.OID, Message: message}
+	if staged != storage.ZeroOID {
+		stagedCommit := commit.Commit{Tree: staged, Parent: head.OID, Message: "index on " + on}
+		stagedOID, err := stagedCommit.Write()
+		if err != nil {
+			return storage.ZeroOID, err
+		}
+		c.OtherParents = []storage.OID{stagedOID}
+	}
+	oid, err := c.Write()
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	err = refs.Update(Ref, oid, message)
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return oid, commit.UpdateWorkingTree(head.Tree, true)
+}
+
+// indexTree writes tree of the index, zero OID if there is no index
+func indexTree() (storage.OID, error) {
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		return storage.ZeroOID, nil
+	}
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	return idx.WriteTree()
+}
+
+// List returns all stash entries, the most recent first
+func List() ([]Entry, error) {
+	log, err := refs.ReadLog(Ref)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, e := range log {
+		entries = append(entries, Entry{OID: e.New, Message: e.Reason})
+	}
+	return entries, nil
+}
+
+// Get returns commit of the stash entry stash@{n}
+func Get(n int) (commit.Commit, error) {
+	oid, err := refs.ReadLogEntry(Ref, n)
+	if err != nil {
+		return commit.Commit{}, err
+	}
+	return commit.GetCommit(oid)
+}
+
+// Apply restores changes saved in the stash entry stash@{n}. Changes are
+// merged with HEAD, relative to the commit the stash was made on, and written
+// to the working tree the way commit.UpdateWorkingTree does: local changes
+// and untracked files are kept, and the stash is refused with
+// commit.DirtyTreeError or commit.UntrackedFilesError if they would be
+// overwritten. The saved index is restored the same way, if the repository
+// has an index. If the merge conflicts, commit.ConflictError is returned and
+// conflicting files are left with conflict markers
+func Apply(n int) error {
+	stashed, err := Get(n)
+	if err != nil {
+		return err
+	}
+	base, err := commit.GetCommit(stashed.Parent)
+	if err != nil {
+		return err
+	}
+	head, err := commit.GetHead()
+	if err != nil {
+		return err
+	}
+	label := fmt.Sprintf("stash@{%d}", n)
+	result, err := merge.Trees(base.Tree, head.Tree, stashed.Tree, "Updated upstream", label)
+	if err != nil {
+		return err
+	}
+	staged, err := stagedTree(stashed, base, head, label)
+	if err != nil {
+		return err
+	}
+	if staged != storage.ZeroOID && result.Clean() {
+		err = commit.UpdateWorkingTreeAndIndex(result.Tree, staged)
+	} else {
+		err = commit.UpdateWorkingTree(result.Tree, false)
+	}
+	if err != nil {
+		return err
+	}
+	if !result.Clean() {
+		return commit.ConflictError{Paths: result.Conflicts}
+	}
+	return nil
+}
+
+// stagedTree merges the index saved in the stash entry with HEAD. Return
+// zero OID if there is nothing to restore it to: the entry has no saved
+// index, the repository has no index, or the merge conflicts
+func stagedTree(stashed, base, head commit.Commit, label string) (storage.OID, error) {
+	if len(stashed.OtherParents) == 0 || !index.Exists() {
+		return storage.ZeroOID, nil
+	}
+	saved, err := commit.GetCommit(stashed.OtherParents[0])
+	if err != nil {
+		return storage.ZeroOID, err
+	}
+	result, err := merge.Trees(base.Tree, head.Tree, saved.Tree, "Updated upstream", label)
+	if err != nil || !result.Clean() {
+		return storage.ZeroOID, err
+	}
+	return result.Tree, nil
+}
+
+// Pop applies the stash entry stash@{n} and removes it from the stack.
+// The entry is kept if applying it conflicts
+func Pop(n int) error {
+	err := Apply(n)
+	if err != nil {
+		return err
+	}
+	return Drop(n)
+}
+
+// Drop removes the stash entry stash@{n} from the stack
+func Drop(n int) error {
+	return refs.DropLogEntry(Ref, n)
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Period is the length of time commits are grouped by
+type Period string
+
+// Periods commits can be grouped by
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+	Year  Period = "year"
+)
+
+// ParsePeriod returns period with given name
+func ParsePeriod(name string) (Period, error) {
+	switch p := Period(name); p {
+	case Day, Week, Month, Year:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown period %q, expected day, week, month or year", name)
+	}
+}
+
+// Key returns name of the period the time belongs to, e.g. 2020-12
+// for a month. Keys of periods sort in chronological order
+func (p Period) Key(t time.Time) string {
+	switch p {
+	case Day:
+		return t.Format("2006-01-02")
+	case Week:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Year:
+		return t.Format("2006")
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// Counts are the numbers of commits and lines they changed
+type Counts struct {
+	Commits int
+	Added   int
+	Removed int
+}
+
+func (c *Counts) add(added, removed int) {
+	c.Commits++
+	c.Added += added
+	c.Removed += removed
+}
+
+// Group is counts for a single author or period
+type Group struct {
+	Name string
+	Counts
+}
+
+// Report summarizes commits
+type Report struct {
+	Total Counts
+	// Authors are ordered by the number of commits, the most active first
+	Authors []Group
+	// Periods are ordered chronologically
+	Periods []Group
+}
+
+// Collect summarizes the commits by author, using name and email, and by
+// period of authoring time. Lines added and removed by every commit are counted
+// by diffing its tree with the tree of its parent
+func Collect(commits []commit.Commit, period Period) (Report, error) {
+	authors := make(map[string]*Counts)
+	periods := make(map[string]*Counts)
+	var report Report
+	for _, c := range commits {
+		added, removed, err := lineStat(c)
+		if err != nil {
+			return Report{}, err
+		}
+		report.Total.add(added, removed)
+		author := c.Author.Identity.String()
+		if authors[author] == nil {
+			authors[author] = &Counts{}
+		}
+		authors[author].add(added, removed)
+		key := period.Key(c.Author.When)
+		if periods[key] == nil {
+			periods[key] = &Counts{}
+		}
+		periods[key].add(added, removed)
+	}
+	report.Authors = groups(authors)
+	sort.SliceStable(report.Authors, func(i, j int) bool {
+		return report.Authors[i].Commits > report.Authors[j].Commits
+	})
+	report.Periods = groups(periods)
+	return report, nil
+}
+
+// groups returns counts as groups ordered by name
+func groups(counts map[string]*Counts) []Group {
+	var result []Group
+	for name, c := range counts {
+		result = append(result, Group{Name: name, Counts: *c})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func lineStat(c commit.Commit) (added, removed int, err error) {
+	parentTree := storage.ZeroOID
+	if c.Parent != storage.ZeroOID {
+		parent, err := commit.GetCommit(c.Parent)
+		if err != nil {
+			return 0, 0, err
+		}
+		parentTree = parent.Tree
+	}
+	changes, err := diff.Trees(parentTree, c.Tree)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, change := range changes {
+		a, r, err := change.Stat()
+		if err != nil {
+			return 0, 0, err
+		}
+		added += a
+		removed += r
+	}
+	return added, removed, nil
+}
@@ -0,0 +1,179 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/i-hate-nicknames/gitik/pkg/commit"
+	"github.com/i-hate-nicknames/gitik/pkg/config"
+	"github.com/i-hate-nicknames/gitik/pkg/diff"
+	"github.com/i-hate-nicknames/gitik/pkg/index"
+	"github.com/i-hate-nicknames/gitik/pkg/plumbing"
+	"github.com/i-hate-nicknames/gitik/pkg/refs"
+	"github.com/i-hate-nicknames/gitik/pkg/revision"
+	"github.com/i-hate-nicknames/gitik/pkg/storage"
+)
+
+// Branch describes what HEAD points to
+type Branch struct {
+	// Name is short name of the checked out branch, empty if HEAD is detached
+	Name string
+	// Head is the commit HEAD points to, zero if there are no commits yet
+	Head storage.OID
+	// Upstream is the revision the branch is compared to, set by
+	// branch.<name>.upstream option, empty if there is none
+	Upstream string
+	// Ahead and Behind are the numbers of commits only the branch
+	// and only the upstream have
+	Ahead, Behind int
+}
+
+// Status is the state of the working tree
+type Status struct {
+	Branch Branch
+	// Staged are the changes of the index relative to HEAD. Without an index,
+	// all changes of the working tree are staged, since all of them are committed
+	Staged []diff.Change
+	// Unstaged are the changes of the working tree relative to the index,
+	// to the files that are in the index
+	Unstaged []diff.Change
+	// Untracked are the files missing from the index
+	Untracked []string
+}
+
+// Clean reports whether there is nothing to commit and nothing to add
+func (s Status) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0
+}
+
+// UpstreamOption returns name of the option that sets upstream of the branch
+func UpstreamOption(branch string) string {
+	return fmt.Sprintf("branch.%s.upstream", branch)
+}
+
+// Get compares the working tree, the index and the tree of HEAD
+func Get() (Status, error) {
+	branch, err := getBranch()
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{Branch: branch}
+	var headTree storage.OID
+	if branch.Head != storage.ZeroOID {
+		head, err := commit.GetCommit(branch.Head)
+		if err != nil {
+			return Status{}, err
+		}
+		headTree = head.Tree
+	}
+	headFiles, err := plumbing.ReadTreeFiles(headTree)
+	if err != nil {
+		return Status{}, err
+	}
+	idx, err := index.Read()
+	if errors.Is(err, index.ErrNoIndex) {
+		working, err := plumbing.HashTreeFiles(".")
+		if err != nil {
+			return Status{}, err
+		}
+		status.Staged = diff.Files(headFiles, working)
+		return status, nil
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	status.Staged = diff.Files(headFiles, idx.Files())
+	status.Unstaged, status.Untracked, err = compareWorking(idx)
+	return status, err
+}
+
+// compareWorking finds files of the working tree that differ from the index,
+// and the ones that are not in it. Files whose stat info matches
+// the index are not read
+func compareWorking(idx *index.Index) ([]diff.Change, []string, error) {
+	var changes []diff.Change
+	var untracked []string
+	seen := make(map[string]bool)
+	err := filepath.Walk(".", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && plumbing.IsIgnored(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name := filepath.ToSlash(p)
+		entry, ok := idx.Get(name)
+		if !ok {
+			untracked = append(untracked, name)
+			return nil
+		}
+		seen[name] = true
+		if entry.Unchanged(info) {
+			return nil
+		}
+		oid, err := plumbing.HashFile(name)
+		if err != nil {
+			return err
+		}
+		if oid != entry.OID {
+			changes = append(changes, diff.Change{Path: name, Old: entry.OID, New: oid})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range idx.Entries() {
+		if !seen[entry.Path] {
+			changes = append(changes, diff.Change{Path: entry.Path, Old: entry.OID})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	sort.Strings(untracked)
+	return changes, untracked, nil
+}
+
+func getBranch() (Branch, error) {
+	var branch Branch
+	target, err := refs.Target(refs.Head)
+	if err != nil {
+		return Branch{}, err
+	}
+	if target != refs.Head {
+		branch.Name = refs.ShortName(target)
+	}
+	branch.Head, err = refs.Read(refs.Head)
+	if errors.Is(err, refs.ErrNotFound) {
+		return branch, nil
+	}
+	if err != nil || branch.Name == "" {
+		return branch, err
+	}
+	branch.Upstream, err = config.GetDefault(UpstreamOption(branch.Name), "")
+	if err != nil || branch.Upstream == "" {
+		return branch, err
+	}
+	upstream, err := revision.ResolveCommit(branch.Upstream)
+	if err != nil {
+		return Branch{}, fmt.Errorf("upstream of %s: %w", branch.Name, err)
+	}
+	ahead, err := commit.LogRange([]storage.OID{branch.Head}, []storage.OID{upstream.OID})
+	if err != nil {
+		return Branch{}, err
+	}
+	behind, err := commit.LogRange([]storage.OID{upstream.OID}, []storage.OID{branch.Head})
+	if err != nil {
+		return Branch{}, err
+	}
+	branch.Ahead, branch.Behind = len(ahead), len(behind)
+	return branch, nil
+}
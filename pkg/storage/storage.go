@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/i-hate-nicknames/gitik/pkg/constants"
 )
@@ -89,11 +90,8 @@ func GetObject(oid OID) (StoredObject, error) {
 // in the git directory using the hash as the name
 // Basically, it's a store mechanism for a content-based database
 func StoreObject(data []byte, objType ObjectType) (OID, error) {
-	header := objType.Encode()
-	header = append(header, byte(0))
-	data = append(header, data...)
-	hash := sha1.Sum(data)
-	oid := OID(hash)
+	data = encodeObject(data, objType)
+	oid := OID(sha1.Sum(data))
 	err := WriteFile(getObjectPath(oid), data)
 	if err != nil {
 		return ZeroOID, err
@@ -101,6 +99,42 @@ func StoreObject(data []byte, objType ObjectType) (OID, error) {
 	return oid, nil
 }
 
+// HashObject calculates id StoreObject would store given data under,
+// without storing it
+func HashObject(data []byte, objType ObjectType) OID {
+	return OID(sha1.Sum(encodeObject(data, objType)))
+}
+
+// encodeObject prepends data with the header of given object type
+func encodeObject(data []byte, objType ObjectType) []byte {
+	header := objType.Encode()
+	header = append(header, byte(0))
+	return append(header, data...)
+}
+
+// FindObjects returns ids of all stored objects whose hex encoding
+// starts with given prefix
+func FindObjects(prefix string) ([]OID, error) {
+	files, err := ioutil.ReadDir(constants.GitDir)
+	if err != nil {
+		return nil, err
+	}
+	var found []OID
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !strings.HasPrefix(f.Name(), prefix) {
+			continue
+		}
+		// objects share the directory with other files, like HEAD,
+		// only names that are valid object ids are objects
+		oid, err := MakeOID([]byte(f.Name()))
+		if err != nil {
+			continue
+		}
+		found = append(found, oid)
+	}
+	return found, nil
+}
+
 // WriteFile writes data to a regular file under given path
 // return error on any i/o error, or if a file with this name already exists
 func WriteFile(path string, data []byte) (err error) {